@@ -15,28 +15,22 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscription": {
+        "/admin/subscriptions/normalize": {
             "post": {
-                "description": "Добавляет новую подписку в систему",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Переименовывает все подписки, название которых совпадает с названием или псевдонимом сервиса из справочника без учёта регистра и лишних пробелов, в каноническое название. Каждое переименование попадает в историю изменений",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "admin"
                 ],
-                "summary": "Создать новую подписку",
+                "summary": "Привести названия подписок к справочнику",
                 "parameters": [
                     {
-                        "description": "Данные подписки",
-                        "name": "subscription",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.SubscriptionDTO"
-                        }
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
                     }
                 ],
                 "responses": {
@@ -45,34 +39,115 @@ const docTemplate = `{
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "$ref": "#/definitions/models.NormalizeResult"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/subscriptions/purge": {
+            "post": {
+                "description": "Окончательно удаляет подписки, помеченные удалёнными раньше, чем retention_days дней назад",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Очистить удалённые подписки",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Срок хранения удалённых подписок в днях (по умолчанию 30)",
+                        "name": "retention_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "$ref": "#/definitions/models.PurgeResult"
                             }
                         }
                     },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/budgets": {
+            "get": {
+                "description": "Возвращает бюджеты пользователя или все бюджеты, если user_id не задан",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "budgets"
+                ],
+                "summary": "Получить список бюджетов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.BudgetDTO"
+                                }
                             }
                         }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
                     }
                 }
-            }
-        },
-        "/subscription/{id}": {
-            "put": {
-                "description": "Изменяет данные существующей подписки",
+            },
+            "post": {
+                "description": "Добавляет лимит расходов пользователя на подписки: на каждый месяц (period=monthly) или на весь период start_date–end_date (period=period). Без service_name лимит действует на все сервисы",
                 "consumes": [
                     "application/json"
                 ],
@@ -80,70 +155,112 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "budgets"
                 ],
-                "summary": "Обновить подписку",
+                "summary": "Создать бюджет",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "ID подписки",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Обновленные данные подписки",
-                        "name": "subscription",
+                        "description": "Данные бюджета",
+                        "name": "budget",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.SubscriptionDTO"
+                            "$ref": "#/definitions/models.BudgetDTO"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "$ref": "#/definitions/models.SubscriptionDTO"
+                                "$ref": "#/definitions/models.BudgetDTO"
+                            }
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Адрес созданного бюджета: /budgets/{id}"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/budgets/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "budgets"
+                ],
+                "summary": "Получить бюджет по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID бюджета",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "string"
+                                "$ref": "#/definitions/models.BudgetDTO"
                             }
                         }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
                     }
                 }
             },
             "delete": {
-                "description": "Удаляет подписку по ID",
+                "description": "Удаляет бюджет вместе с зафиксированными превышениями",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "budgets"
                 ],
-                "summary": "Удалить подписку",
+                "summary": "Удалить бюджет",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID подписки",
+                        "description": "ID бюджета",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -159,28 +276,40 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscriptions": {
+        "/budgets/{id}/breaches": {
             "get": {
-                "description": "Возвращает список всех подписок пользователей",
+                "description": "Возвращает превышения, зафиксированные фоновой проверкой, от новых периодов к старым",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "budgets"
+                ],
+                "summary": "Получить превышения бюджета",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID бюджета",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Получить все подписки",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -189,59 +318,49 @@ const docTemplate = `{
                             "additionalProperties": {
                                 "type": "array",
                                 "items": {
-                                    "$ref": "#/definitions/models.SubscriptionDTO"
+                                    "$ref": "#/definitions/models.BudgetBreach"
                                 }
                             }
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/aggregate/total": {
+        "/budgets/{id}/evaluation": {
             "get": {
-                "description": "Возвращает итоговую стоимость всех подписок по фильтрам",
+                "description": "Сравнивает расходы пользователя (итог агрегации, пересчитанный в валюту бюджета) с лимитом. Для помесячного бюджета — за месяц month, для бюджета на период — с начала периода по текущий месяц",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "budgets"
                 ],
-                "summary": "Получить общую стоимость подписок",
+                "summary": "Проверить бюджет",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "ID пользователя",
-                        "name": "user_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Название подписки",
-                        "name": "service_name",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Дата начала периода",
-                        "name": "start_date",
-                        "in": "query",
+                        "description": "ID бюджета",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Дата конца периода",
-                        "name": "end_date",
-                        "in": "query",
-                        "required": true
+                        "description": "Месяц помесячного бюджета (MM-YYYY, по умолчанию текущий)",
+                        "name": "month",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -250,77 +369,2687 @@ const docTemplate = `{
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "type": "integer",
-                                "format": "int64"
+                                "$ref": "#/definitions/models.BudgetEvaluation"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
             }
         },
-        "/subscriptions/{id}": {
+        "/catalog": {
             "get": {
-                "description": "Возвращает информацию о конкретной подписке",
+                "description": "Возвращает все сервисы справочника по алфавиту: каноническое название, псевдонимы, категорию и цену по умолчанию",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
-                ],
-                "summary": "Получить подписку по ID",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "ID подписки",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "catalog"
                 ],
+                "summary": "Получить справочник сервисов",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "object",
                             "additionalProperties": {
-                                "$ref": "#/definitions/models.SubscriptionDTO"
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.CatalogServiceDTO"
+                                }
                             }
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Problem"
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "models.SubscriptionDTO": {
-            "type": "object",
-            "properties": {
-                "end_date": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "price": {
-                    "type": "integer"
+            },
+            "post": {
+                "description": "Добавляет сервис с каноническим названием и псевдонимами. Названия подписок, совпадающие с названием или псевдонимом без учёта регистра и лишних пробелов, приводятся к каноническому — и у новых подписок, и у уже сохранённых",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Добавить сервис в справочник",
+                "parameters": [
+                    {
+                        "description": "Данные сервиса",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CatalogServiceDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.CatalogServiceDTO"
+                            }
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Адрес созданной записи: /catalog/{id}"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/catalog/{id}": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Получить сервис справочника по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID сервиса",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.CatalogServiceDTO"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Полностью заменяет запись справочника, включая список псевдонимов. Прежнее название остаётся псевдонимом, сохранённые подписки переименовываются в новое название",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Обновить сервис справочника",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID сервиса",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные сервиса",
+                        "name": "service",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CatalogServiceDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.CatalogServiceDTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Удаляет сервис вместе с псевдонимами. Подписки не меняются, в итогах по категориям они попадают в uncategorized",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "catalog"
+                ],
+                "summary": "Удалить сервис из справочника",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID сервиса",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription": {
+            "post": {
+                "description": "Добавляет новую подписку в систему и возвращает сохранённую запись. Название сервиса из справочника приводится к каноническому, а без цены подставляется цена по умолчанию из справочника",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Создать новую подписку",
+                "parameters": [
+                    {
+                        "description": "Данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            },
+                            "Location": {
+                                "type": "string",
+                                "description": "Адрес созданной подписки: /subscription/{id}"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}": {
+            "put": {
+                "description": "Изменяет данные существующей подписки. Новая цена действует с месяца price_effective_from (по умолчанию текущего), прошлые месяцы считаются по прежней цене. Требует If-Match с ETag текущей версии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Обновленные данные подписки",
+                        "name": "subscription",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Помечает подписку удалённой (её можно восстановить до очистки). Требует If-Match с ETag текущей версии",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Удалить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Применяет JSON Merge Patch (RFC 7396): переданные поля заменяются, null удаляет необязательное поле (например, end_date). Проверяется итоговая запись, сохраняются только изменённые поля. Требует If-Match с ETag текущей версии",
+                "consumes": [
+                    "application/json",
+                    "application/merge-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Частично обновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Изменяемые поля подписки",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Новая версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/cancel": {
+            "post": {
+                "description": "Переводит подписку в cancelled и задаёт дату окончания end_date (по умолчанию — текущий месяц или, для дат с точностью до дня, текущий день). Отменённую подписку нельзя возобновить. Требует If-Match с ETag текущей версии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Отменить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Дата окончания",
+                        "name": "change",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusChangeDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/discounts": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "discounts"
+                ],
+                "summary": "Получить скидки подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.SubscriptionDiscountDTO"
+                                }
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Добавляет процентную (kind=percent) или фиксированную (kind=fixed) скидку, действующую в месяцах valid_from–valid_until. Скидки применяются к каждому списанию в пределах срока действия: сначала процентные, затем фиксированные. Версия подписки увеличивается, добавление попадает в историю изменений",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "discounts"
+                ],
+                "summary": "Добавить скидку на подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Данные скидки",
+                        "name": "discount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionDiscountDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDiscountDTO"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/discounts/{discount_id}": {
+            "delete": {
+                "description": "Версия подписки увеличивается, удаление попадает в историю изменений",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "discounts"
+                ],
+                "summary": "Удалить скидку подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID скидки",
+                        "name": "discount_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/history": {
+            "get": {
+                "description": "Возвращает изменения подписки от старых к новым: действие, время, автора и состояние до и после. История сохраняется и после удаления подписки",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Получить историю изменений подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.SubscriptionChange"
+                                }
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/pause": {
+            "post": {
+                "description": "Переводит подписку из trial или active в paused с месяца from (по умолчанию текущего). Месяцы паузы не учитываются в итогах. Требует If-Match с ETag текущей версии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Приостановить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Месяц начала паузы",
+                        "name": "change",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusChangeDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/prices": {
+            "get": {
+                "description": "Возвращает цены подписки с месяцами, с которых они действуют. Стоимость за прошлые месяцы считается по действовавшей тогда цене",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить историю цен подписки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.PricePoint"
+                                }
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/restore": {
+            "post": {
+                "description": "Снимает пометку удаления с подписки; для действующей подписки возвращает её без изменений. Требует If-Match с ETag текущей версии (удаление увеличивает версию на единицу)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Восстановить удалённую подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscription/{id}/resume": {
+            "post": {
+                "description": "Переводит приостановленную подписку в active с месяца from (по умолчанию текущего). Требует If-Match с ETag текущей версии",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Возобновить подписку",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag текущей версии подписки или *",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Месяц возобновления",
+                        "name": "change",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.StatusChangeDTO"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions": {
+            "get": {
+                "description": "Возвращает страницу подписок пользователей с фильтрами, сортировкой и общим числом записей.\nВ форматах csv, excel и jsonl (параметр format или заголовок Accept) записи выгружаются построчно; без limit — все подходящие",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить список подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Действующие в месяце (MM-YYYY) или в день (YYYY-MM-DD)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Минимальная цена",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Максимальная цена",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Колонки сортировки через запятую, минус — по убыванию, например -price,service_name",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (1–1000, по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение от начала выборки",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Включить мягко удалённые подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, csv, excel (CSV для Excel) или jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionPage"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/aggregate/breakdown": {
+            "get": {
+                "description": "Возвращает итоговую стоимость за период и промежуточные итоги по названию подписки, её категории, пользователю и/или значению тега: gross — без скидок, subtotal — с их учётом. Сервисы вне справочника попадают в категорию uncategorized",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить стоимость подписок с разбивкой по группам",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала периода",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата конца периода",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта, в которую пересчитываются суммы (ISO 4217)",
+                        "name": "convert_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать мягко удалённые подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Измерения группировки через запятую: service_name, user_id, category (категория из справочника сервисов), tag:\u003cключ\u003e (значение тега, не больше одного ключа)",
+                        "name": "group_by",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить число учтённых месяцев по каждой подписке",
+                        "name": "details",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, csv, excel (CSV для Excel) или jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AggregateResult"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/aggregate/monthly": {
+            "get": {
+                "description": "Возвращает по одной записи на каждый календарный месяц периода: сумму, число активных подписок и разбивку по сервисам",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить помесячную стоимость подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала периода",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата конца периода",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта, в которую пересчитываются суммы (ISO 4217)",
+                        "name": "convert_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать мягко удалённые подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, csv, excel (CSV для Excel) или jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.MonthlyBucket"
+                                }
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/aggregate/normalized": {
+            "get": {
+                "description": "Возвращает подписки, активные в указанном месяце, со стоимостью, пересчитанной на один месяц независимо от периодичности списания",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить стоимость подписок, приведённую к месяцу",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Месяц в формате MM-YYYY, по умолчанию текущий",
+                        "name": "month",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, csv, excel (CSV для Excel) или jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.NormalizedCost"
+                                }
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/aggregate/total": {
+            "get": {
+                "description": "Возвращает итоговую стоимость всех подписок по фильтрам",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить общую стоимость подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test",
+                        "name": "tag",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата начала периода",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Дата конца периода",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта, в которую пересчитываются суммы (ISO 4217)",
+                        "name": "convert_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Учитывать мягко удалённые подписки",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить число учтённых месяцев по каждой подписке",
+                        "name": "details",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Формат ответа: json, csv, excel (CSV для Excel) или jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AggregateResult"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/changes": {
+            "get": {
+                "description": "Возвращает изменения всех подписок за интервал времени [from, to) от старых к новым",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "history"
+                ],
+                "summary": "Получить ленту изменений подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Начало интервала (RFC 3339 или YYYY-MM-DD)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец интервала, не включая (RFC 3339 или YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Размер страницы (1–1000, по умолчанию 50)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Смещение от начала выборки",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ChangePage"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/import": {
+            "post": {
+                "description": "Создаёт подписки из CSV (первая строка — заголовок с названиями полей) или JSON Lines (одна подписка на строку). Каждая строка проверяется как в POST /subscription. В режиме atomic при ошибке хотя бы в одной строке не сохраняется ничего, в режиме best_effort сохраняются все корректные строки. Возвращает результат по каждой строке",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Массовый импорт подписок",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "atomic (по умолчанию) или best_effort",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "csv или jsonl; по умолчанию определяется по Content-Type",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Кто выполняет изменение (для истории изменений)",
+                        "name": "X-Actor",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.ImportReport"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/trials": {
+            "get": {
+                "description": "Возвращает подписки, у которых первое платное списание после пробного периода приходится на ближайшие within_days дней, — чтобы заранее предупредить пользователя. Сумма списания учитывает вводную цену и скидки",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Окончания пробных периодов",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Сколько дней вперёд смотреть (1–365, по умолчанию 30)",
+                        "name": "within_days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "array",
+                                "items": {
+                                    "$ref": "#/definitions/models.TrialEnding"
+                                }
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "description": "Возвращает информацию о конкретной подписке",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Получить подписку по ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID подписки",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.SubscriptionDTO"
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Версия подписки; передаётся в If-Match при изменении и удалении"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{user_id}/forecast": {
+            "get": {
+                "description": "Проецирует расходы пользователя на текущий и следующие месяцы: итог, разбивка по сервисам и по каждому месяцу. Бессрочные подписки считаются продолжающимися, запланированные окончания и будущие начала учитываются",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Прогноз расходов пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Горизонт прогноза в месяцах (1–60, по умолчанию 12)",
+                        "name": "months",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Название подписки или его псевдоним из справочника",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Валюта, в которую пересчитываются суммы (ISO 4217)",
+                        "name": "convert_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/models.Forecast"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{user_id}/renewals.ics": {
+            "get": {
+                "description": "Возвращает календарь iCalendar (RFC 5545) с повторяющимся событием на каждую действующую или будущую подписку. Повторения следуют периодичности списания, начинаются с первого платного списания после пробного периода и заканчиваются с окончанием подписки",
+                "produces": [
+                    "text/calendar"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Календарь продлений подписок пользователя",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID пользователя",
+                        "name": "user_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Календарь в формате iCalendar",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.AggregateGroup": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "gross": {
+                    "description": "Сумма без скидок; Subtotal — с учётом скидок",
+                    "type": "integer"
+                },
+                "months": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subtotal": {
+                    "type": "integer"
+                },
+                "tag": {
+                    "description": "Тег с ключом группировки (\"team:backend\"); пусто у подписок без такого тега",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AggregateResult": {
+            "type": "object",
+            "properties": {
+                "groups": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AggregateGroup"
+                    }
+                },
+                "subscriptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionTotal"
+                    }
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "totals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CurrencyTotal"
+                    }
+                }
+            }
+        },
+        "models.BillingPeriod": {
+            "type": "string",
+            "enum": [
+                "weekly",
+                "monthly",
+                "quarterly",
+                "yearly"
+            ],
+            "x-enum-varnames": [
+                "BillingWeekly",
+                "BillingMonthly",
+                "BillingQuarterly",
+                "BillingYearly"
+            ]
+        },
+        "models.BudgetBreach": {
+            "type": "object",
+            "properties": {
+                "budget_id": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "detected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "period_end": {
+                    "type": "string"
+                },
+                "period_start": {
+                    "type": "string"
+                },
+                "spent": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.BudgetDTO": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "period": {
+                    "$ref": "#/definitions/models.BudgetPeriod"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "description": "Границы периода в формате MM-YYYY; только для period",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BudgetEvaluation": {
+            "type": "object",
+            "properties": {
+                "budget_id": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "exceeded": {
+                    "type": "boolean"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "remaining": {
+                    "description": "Остаток лимита; отрицательный при превышении",
+                    "type": "integer"
+                },
+                "spent": {
+                    "description": "Расходы с начала периода, но не позже текущего месяца",
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BudgetPeriod": {
+            "type": "string",
+            "enum": [
+                "monthly",
+                "period"
+            ],
+            "x-enum-varnames": [
+                "BudgetMonthly",
+                "BudgetPeriodRange"
+            ]
+        },
+        "models.CatalogServiceDTO": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "Другие написания названия, например \"netflix\" или \"Нетфликс\"",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "default_price": {
+                    "description": "Цена по умолчанию в валюте currency",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ChangeAction": {
+            "type": "string",
+            "enum": [
+                "create",
+                "update",
+                "delete",
+                "restore",
+                "purge",
+                "pause",
+                "resume",
+                "cancel",
+                "discount_add",
+                "discount_remove"
+            ],
+            "x-enum-varnames": [
+                "ChangeCreate",
+                "ChangeUpdate",
+                "ChangeDelete",
+                "ChangeRestore",
+                "ChangePurge",
+                "ChangePause",
+                "ChangeResume",
+                "ChangeCancel",
+                "ChangeDiscountAdd",
+                "ChangeDiscountRemove"
+            ]
+        },
+        "models.ChangePage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionChange"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.CurrencyTotal": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DiscountKind": {
+            "type": "string",
+            "enum": [
+                "percent",
+                "fixed"
+            ],
+            "x-enum-varnames": [
+                "DiscountPercent",
+                "DiscountFixed"
+            ]
+        },
+        "models.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Forecast": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "string"
+                },
+                "months": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MonthlyBucket"
+                    }
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceTotal"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "totals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CurrencyTotal"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImportMode": {
+            "type": "string",
+            "enum": [
+                "atomic",
+                "best_effort"
+            ],
+            "x-enum-varnames": [
+                "ImportAtomic",
+                "ImportBestEffort"
+            ]
+        },
+        "models.ImportReport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "mode": {
+                    "$ref": "#/definitions/models.ImportMode"
+                },
+                "rows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImportRowResult"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ImportRowResult": {
+            "type": "object",
+            "properties": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.ImportStatus"
+                }
+            }
+        },
+        "models.ImportStatus": {
+            "type": "string",
+            "enum": [
+                "created",
+                "failed",
+                "skipped"
+            ],
+            "x-enum-varnames": [
+                "ImportCreated",
+                "ImportFailed",
+                "ImportSkipped"
+            ]
+        },
+        "models.MonthlyBucket": {
+            "type": "object",
+            "properties": {
+                "active_subscriptions": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "string"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ServiceTotal"
+                    }
+                },
+                "total_price": {
+                    "type": "integer"
+                },
+                "totals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CurrencyTotal"
+                    }
+                }
+            }
+        },
+        "models.NormalizeResult": {
+            "type": "object",
+            "properties": {
+                "renamed": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.NormalizedCost": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "$ref": "#/definitions/models.BillingPeriod"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "monthly_cost": {
+                    "type": "number"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PricePoint": {
+            "type": "object",
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PurgeResult": {
+            "type": "object",
+            "properties": {
+                "deleted_before": {
+                    "type": "string"
+                },
+                "purged": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ServiceTotal": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.StatusChangeDTO": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Дата окончания при отмене (MM-YYYY или YYYY-MM-DD); по умолчанию текущий месяц",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "Месяц (MM-YYYY), с которого действует пауза или возобновление; по умолчанию текущий",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/models.ChangeAction"
+                },
+                "actor": {
+                    "description": "Кто выполнил изменение",
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "description": "Состояние подписки до и после изменения; before пуст при создании, after — при удалении",
+                    "type": "object"
+                },
+                "changed_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "subscription_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionDTO": {
+            "type": "object",
+            "properties": {
+                "billing_period": {
+                    "$ref": "#/definitions/models.BillingPeriod"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "description": "Время удаления (RFC 3339); только для чтения, заполнено у удалённых подписок",
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "intro_months": {
+                    "type": "integer"
+                },
+                "intro_price": {
+                    "description": "Вводная цена и число месяцев, на которые она действует после пробного периода",
+                    "type": "integer"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "price_effective_from": {
+                    "description": "Месяц (MM-YYYY), с которого действует новая цена при её изменении;\nпо умолчанию — текущий месяц. Только для записи",
+                    "type": "string"
                 },
                 "service_name": {
                     "type": "string"
@@ -328,6 +3057,150 @@ const docTemplate = `{
                 "start_date": {
                     "type": "string"
                 },
+                "status": {
+                    "description": "Статус; при создании — trial или active (по умолчанию), дальше меняется\nтолько допустимыми переходами",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SubscriptionStatus"
+                        }
+                    ]
+                },
+                "tags": {
+                    "description": "Теги вида \"key:value\" или \"key\", например [\"team:backend\", \"cost-center:42\"];\nу одного ключа может быть только одно значение",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "trial_months": {
+                    "description": "Бесплатный пробный период в месяцах; подписка создаётся в статусе trial",
+                    "type": "integer"
+                },
+                "trial_until": {
+                    "description": "Первый оплачиваемый месяц после пробного периода (MM-YYYY), для дат\nс точностью до дня — первый оплачиваемый день (YYYY-MM-DD); только для чтения",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionDiscountDTO": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "description": "percent или fixed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DiscountKind"
+                        }
+                    ]
+                },
+                "valid_from": {
+                    "description": "Границы действия в формате MM-YYYY, включительно",
+                    "type": "string"
+                },
+                "valid_until": {
+                    "type": "string"
+                },
+                "value": {
+                    "description": "Процент (1–100) или сумма за одно списание",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SubscriptionPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionDTO"
+                    }
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SubscriptionStatus": {
+            "type": "string",
+            "enum": [
+                "trial",
+                "active",
+                "paused",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "StatusTrial",
+                "StatusActive",
+                "StatusPaused",
+                "StatusCancelled"
+            ]
+        },
+        "models.SubscriptionTotal": {
+            "type": "object",
+            "properties": {
+                "charges": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "gross": {
+                    "description": "Сумма без скидок; Subtotal — с учётом скидок",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "months": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "subtotal": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TrialEnding": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "first_charge_amount": {
+                    "type": "integer"
+                },
+                "first_charge_date": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "trial_until": {
+                    "type": "string"
+                },
                 "user_id": {
                     "type": "string"
                 }
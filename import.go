@@ -0,0 +1,81 @@
+package main
+
+import (
+	"aggregationSubscriptions/internal/utils"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Подкоманда import: массовый импорт подписок из CSV или JSONL.
+// Отчёт по строкам печатается в stdout, код выхода 1 — есть ошибочные строки
+//
+//	Subscriptions import [-mode atomic|best_effort] [-format csv|jsonl] [-actor имя] файл
+func runImport(args []string) int {
+	// Логи — в stderr, чтобы stdout содержал только отчёт
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	modeFlag := flags.String("mode", "atomic", "режим импорта: atomic или best_effort")
+	formatFlag := flags.String("format", "", "формат файла: csv или jsonl (по умолчанию по расширению)")
+	actorFlag := flags.String("actor", os.Getenv("USER"), "кто выполняет импорт (для истории изменений)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "использование: Subscriptions import [-mode atomic|best_effort] [-format csv|jsonl] [-actor имя] файл")
+		return 2
+	}
+	path := flags.Arg(0)
+
+	mode, err := utils.ParseImportMode(*modeFlag)
+	if err != nil {
+		slog.Error("Неверный режим импорта", "error", err)
+		return 2
+	}
+
+	format := *formatFlag
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	importFormat, err := utils.ParseImportFormat(format, "")
+	if err != nil {
+		slog.Error("Неверный формат импорта", "error", err)
+		return 2
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error("Не удалось открыть файл импорта", "error", err)
+		return 1
+	}
+	defer file.Close()
+
+	records, err := utils.DecodeImport(file, importFormat)
+	if err != nil {
+		slog.Error("Не удалось разобрать файл импорта", "error", err)
+		return 1
+	}
+
+	report, err := newService().ImportSubscriptions(records, mode, *actorFlag)
+	if err != nil {
+		slog.Error("Не удалось импортировать подписки", "error", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		slog.Error("Не удалось вывести отчёт импорта", "error", err)
+		return 1
+	}
+
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
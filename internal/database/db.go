@@ -34,7 +34,8 @@ func Connect() {
 		host, user, password, name, port)
 
 	var err error
-	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// TranslateError приводит ошибки драйвера к gorm.ErrDuplicatedKey и т.п.
+	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		slog.Error("Не удалось подключиться к БД",
 			slog.String("error", err.Error()))
@@ -45,7 +46,9 @@ func Connect() {
 }
 
 func Migrate() {
-	if err := db.AutoMigrate(&models.Subscription{}); err != nil {
+	if err := db.AutoMigrate(&models.Subscription{}, &models.SubscriptionPrice{}, &models.SubscriptionChange{},
+		&models.SubscriptionPause{}, &models.SubscriptionDiscount{}, &models.Budget{}, &models.BudgetBreach{},
+		&models.CatalogService{}, &models.CatalogAlias{}); err != nil {
 		slog.Error("Ошибка миграции", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+// CreateBudget godoc
+// @Summary      Создать бюджет
+// @Description  Добавляет лимит расходов пользователя на подписки: на каждый месяц (period=monthly) или на весь период start_date–end_date (period=period). Без service_name лимит действует на все сервисы
+// @Tags         budgets
+// @Accept       json
+// @Produce      json
+// @Param        budget  body      models.BudgetDTO  true  "Данные бюджета"
+// @Success      201  {object}  map[string]models.BudgetDTO
+// @Header       201  {string}  Location  "Адрес созданного бюджета: /budgets/{id}"
+// @Failure      400  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets [post]
+func (h *Handler) CreateBudget(c *gin.Context) {
+	var dto models.BudgetDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	created, err := h.service.CreateBudget(dto)
+	if err != nil {
+		respondError(c, err, "Не удалось создать бюджет")
+		return
+	}
+
+	slog.Info("Бюджет был успешно создан")
+	c.Header("Location", "/budgets/"+created.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": created})
+}
+
+// GetBudgets godoc
+// @Summary      Получить список бюджетов
+// @Description  Возвращает бюджеты пользователя или все бюджеты, если user_id не задан
+// @Tags         budgets
+// @Produce      json
+// @Param        user_id  query     string  false  "ID пользователя"
+// @Success      200  {object}  map[string][]models.BudgetDTO
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets [get]
+func (h *Handler) GetBudgets(c *gin.Context) {
+	budgets, err := h.service.GetBudgets(c.Query("user_id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить бюджеты")
+		return
+	}
+
+	slog.Info("Бюджеты успешно получены")
+	c.JSON(http.StatusOK, gin.H{"data": budgets})
+}
+
+// GetBudget godoc
+// @Summary      Получить бюджет по ID
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      string  true  "ID бюджета"
+// @Success      200  {object}  map[string]models.BudgetDTO
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets/{id} [get]
+func (h *Handler) GetBudget(c *gin.Context) {
+	budget, err := h.service.GetBudget(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить бюджет")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": budget})
+}
+
+// DeleteBudget godoc
+// @Summary      Удалить бюджет
+// @Description  Удаляет бюджет вместе с зафиксированными превышениями
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      string  true  "ID бюджета"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets/{id} [delete]
+func (h *Handler) DeleteBudget(c *gin.Context) {
+	if err := h.service.DeleteBudget(c.Param("id")); err != nil {
+		respondError(c, err, "Не удалось удалить бюджет")
+		return
+	}
+
+	slog.Info("Бюджет был успешно удалён")
+	c.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+// EvaluateBudget godoc
+// @Summary      Проверить бюджет
+// @Description  Сравнивает расходы пользователя (итог агрегации, пересчитанный в валюту бюджета) с лимитом. Для помесячного бюджета — за месяц month, для бюджета на период — с начала периода по текущий месяц
+// @Tags         budgets
+// @Produce      json
+// @Param        id     path      string  true   "ID бюджета"
+// @Param        month  query     string  false  "Месяц помесячного бюджета (MM-YYYY, по умолчанию текущий)"
+// @Success      200  {object}  map[string]models.BudgetEvaluation
+// @Failure      404  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets/{id}/evaluation [get]
+func (h *Handler) EvaluateBudget(c *gin.Context) {
+	evaluation, err := h.service.EvaluateBudget(c.Param("id"), c.Query("month"))
+	if err != nil {
+		respondError(c, err, "Не удалось проверить бюджет")
+		return
+	}
+
+	slog.Info("Бюджет успешно проверен")
+	c.JSON(http.StatusOK, gin.H{"data": evaluation})
+}
+
+// GetBudgetBreaches godoc
+// @Summary      Получить превышения бюджета
+// @Description  Возвращает превышения, зафиксированные фоновой проверкой, от новых периодов к старым
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path      string  true  "ID бюджета"
+// @Success      200  {object}  map[string][]models.BudgetBreach
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /budgets/{id}/breaches [get]
+func (h *Handler) GetBudgetBreaches(c *gin.Context) {
+	breaches, err := h.service.GetBudgetBreaches(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить превышения бюджета")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": breaches})
+}
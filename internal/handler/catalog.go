@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+// GetCatalog godoc
+// @Summary      Получить справочник сервисов
+// @Description  Возвращает все сервисы справочника по алфавиту: каноническое название, псевдонимы, категорию и цену по умолчанию
+// @Tags         catalog
+// @Produce      json
+// @Success      200  {object}  map[string][]models.CatalogServiceDTO
+// @Failure      500  {object}  models.Problem
+// @Router       /catalog [get]
+func (h *Handler) GetCatalog(c *gin.Context) {
+	services, err := h.service.GetCatalog()
+	if err != nil {
+		respondError(c, err, "Не удалось получить справочник сервисов")
+		return
+	}
+
+	slog.Info("Справочник сервисов успешно получен")
+	c.JSON(http.StatusOK, gin.H{"data": services})
+}
+
+// GetCatalogService godoc
+// @Summary      Получить сервис справочника по ID
+// @Tags         catalog
+// @Produce      json
+// @Param        id   path      string  true  "ID сервиса"
+// @Success      200  {object}  map[string]models.CatalogServiceDTO
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /catalog/{id} [get]
+func (h *Handler) GetCatalogService(c *gin.Context) {
+	svc, err := h.service.GetCatalogService(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить сервис справочника")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": svc})
+}
+
+// CreateCatalogService godoc
+// @Summary      Добавить сервис в справочник
+// @Description  Добавляет сервис с каноническим названием и псевдонимами. Названия подписок, совпадающие с названием или псевдонимом без учёта регистра и лишних пробелов, приводятся к каноническому — и у новых подписок, и у уже сохранённых
+// @Tags         catalog
+// @Accept       json
+// @Produce      json
+// @Param        service  body      models.CatalogServiceDTO  true  "Данные сервиса"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      201  {object}  map[string]models.CatalogServiceDTO
+// @Header       201  {string}  Location  "Адрес созданной записи: /catalog/{id}"
+// @Failure      400  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /catalog [post]
+func (h *Handler) CreateCatalogService(c *gin.Context) {
+	var dto models.CatalogServiceDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	created, err := h.service.CreateCatalogService(dto, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось добавить сервис в справочник")
+		return
+	}
+
+	slog.Info("Сервис был успешно добавлен в справочник")
+	c.Header("Location", "/catalog/"+created.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": created})
+}
+
+// UpdateCatalogService godoc
+// @Summary      Обновить сервис справочника
+// @Description  Полностью заменяет запись справочника, включая список псевдонимов. Прежнее название остаётся псевдонимом, сохранённые подписки переименовываются в новое название
+// @Tags         catalog
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                    true  "ID сервиса"
+// @Param        service  body      models.CatalogServiceDTO  true  "Данные сервиса"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.CatalogServiceDTO
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /catalog/{id} [put]
+func (h *Handler) UpdateCatalogService(c *gin.Context) {
+	var dto models.CatalogServiceDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	updated, err := h.service.UpdateCatalogService(c.Param("id"), dto, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось обновить сервис справочника")
+		return
+	}
+
+	slog.Info("Сервис справочника был успешно обновлён")
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// DeleteCatalogService godoc
+// @Summary      Удалить сервис из справочника
+// @Description  Удаляет сервис вместе с псевдонимами. Подписки не меняются, в итогах по категориям они попадают в uncategorized
+// @Tags         catalog
+// @Produce      json
+// @Param        id   path      string  true  "ID сервиса"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /catalog/{id} [delete]
+func (h *Handler) DeleteCatalogService(c *gin.Context) {
+	if err := h.service.DeleteCatalogService(c.Param("id")); err != nil {
+		respondError(c, err, "Не удалось удалить сервис из справочника")
+		return
+	}
+
+	slog.Info("Сервис был успешно удалён из справочника")
+	c.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
+
+// NormalizeServiceNames godoc
+// @Summary      Привести названия подписок к справочнику
+// @Description  Переименовывает все подписки, название которых совпадает с названием или псевдонимом сервиса из справочника без учёта регистра и лишних пробелов, в каноническое название. Каждое переименование попадает в историю изменений
+// @Tags         admin
+// @Produce      json
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.NormalizeResult
+// @Failure      500  {object}  models.Problem
+// @Router       /admin/subscriptions/normalize [post]
+func (h *Handler) NormalizeServiceNames(c *gin.Context) {
+	result, err := h.service.NormalizeServiceNames(actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось привести названия подписок к справочнику")
+		return
+	}
+
+	slog.Info("Названия подписок приведены к справочнику", "renamed", result.Renamed)
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
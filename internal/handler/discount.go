@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/service"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetSubscriptionDiscounts godoc
+// @Summary      Получить скидки подписки
+// @Tags         discounts
+// @Produce      json
+// @Param        id   path      string  true  "ID подписки"
+// @Success      200  {object}  map[string][]models.SubscriptionDiscountDTO
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/discounts [get]
+func (h *Handler) GetSubscriptionDiscounts(c *gin.Context) {
+	discounts, err := h.service.GetSubscriptionDiscounts(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить скидки подписки")
+		return
+	}
+
+	slog.Info("Скидки подписки успешно получены")
+	c.JSON(http.StatusOK, gin.H{"data": discounts})
+}
+
+// AddSubscriptionDiscount godoc
+// @Summary      Добавить скидку на подписку
+// @Description  Добавляет процентную (kind=percent) или фиксированную (kind=fixed) скидку, действующую в месяцах valid_from–valid_until. Скидки применяются к каждому списанию в пределах срока действия: сначала процентные, затем фиксированные. Версия подписки увеличивается, добавление попадает в историю изменений
+// @Tags         discounts
+// @Accept       json
+// @Produce      json
+// @Param        id        path  string                          true  "ID подписки"
+// @Param        discount  body  models.SubscriptionDiscountDTO  true  "Данные скидки"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      201  {object}  map[string]models.SubscriptionDiscountDTO
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/discounts [post]
+func (h *Handler) AddSubscriptionDiscount(c *gin.Context) {
+	var dto models.SubscriptionDiscountDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	created, err := h.service.AddSubscriptionDiscount(c.Param("id"), dto, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось добавить скидку")
+		return
+	}
+
+	slog.Info("Скидка была успешно добавлена")
+	c.JSON(http.StatusCreated, gin.H{"data": created})
+}
+
+// DeleteSubscriptionDiscount godoc
+// @Summary      Удалить скидку подписки
+// @Description  Версия подписки увеличивается, удаление попадает в историю изменений
+// @Tags         discounts
+// @Produce      json
+// @Param        id           path  string  true  "ID подписки"
+// @Param        discount_id  path  int     true  "ID скидки"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/discounts/{discount_id} [delete]
+func (h *Handler) DeleteSubscriptionDiscount(c *gin.Context) {
+	discountID, err := strconv.ParseUint(c.Param("discount_id"), 10, 64)
+	if err != nil {
+		respondError(c, service.ErrDiscountNotFound, "Не удалось удалить скидку")
+		return
+	}
+
+	if err := h.service.DeleteSubscriptionDiscount(c.Param("id"), discountID, actor(c)); err != nil {
+		respondError(c, err, "Не удалось удалить скидку")
+		return
+	}
+
+	slog.Info("Скидка была успешно удалена")
+	c.JSON(http.StatusOK, gin.H{"data": "OK"})
+}
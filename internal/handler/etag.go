@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/repository"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag подписки — её версия в виде строгого тега: "3"
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// Ожидаемая версия записи из заголовка If-Match.
+// Без заголовка — 428, с тегом, который не может совпасть с версией записи, — 412;
+// в обоих случаях ответ уже отправлен и второе значение false
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		writeProblem(c, http.StatusPreconditionRequired, problemPreconditionRequired, "Требуется заголовок If-Match с ETag подписки", nil)
+		return 0, false
+	}
+	if header == "*" {
+		return repository.AnyVersion, true
+	}
+
+	// Слабые теги (W/"...") при сравнении для If-Match не совпадают никогда
+	tag, err := strconv.Unquote(header)
+	version, convErr := strconv.Atoi(tag)
+	if err != nil || convErr != nil || version <= 0 {
+		writeProblem(c, http.StatusPreconditionFailed, problemPreconditionFailed, "ETag не совпадает с текущей версией подписки", nil)
+		return 0, false
+	}
+	return version, true
+}
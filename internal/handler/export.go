@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/service"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Формат ответа списков и агрегатов
+type exportFormat string
+
+const (
+	exportJSON exportFormat = "json"
+	exportCSV  exportFormat = "csv"
+	// CSV для Excel: BOM, разделитель «;» и десятичная запятая, как ждёт русская локаль
+	exportExcel exportFormat = "excel"
+	exportJSONL exportFormat = "jsonl"
+)
+
+const (
+	mimeCSV   = "text/csv"
+	mimeExcel = "application/vnd.ms-excel"
+	mimeJSONL = "application/x-ndjson"
+)
+
+// Сбрасывать ответ клиенту каждые flushEvery строк
+const flushEvery = 500
+
+// Формат ответа: параметр format или заголовок Accept; по умолчанию JSON
+func responseFormat(c *gin.Context) (exportFormat, error) {
+	switch format := exportFormat(strings.ToLower(c.Query("format"))); format {
+	case "":
+	case exportJSON, exportCSV, exportExcel, exportJSONL:
+		return format, nil
+	default:
+		return "", models.FieldError{Field: "format", Message: "должен быть json, csv, excel или jsonl"}
+	}
+
+	switch c.NegotiateFormat(gin.MIMEJSON, mimeCSV, mimeExcel, mimeJSONL) {
+	case mimeCSV:
+		return exportCSV, nil
+	case mimeExcel:
+		return exportExcel, nil
+	case mimeJSONL:
+		return exportJSONL, nil
+	}
+	return exportJSON, nil
+}
+
+// Формат ответа запроса; при неверном параметре format ответ с ошибкой уже отправлен
+func requestFormat(c *gin.Context) (exportFormat, bool) {
+	format, err := responseFormat(c)
+	if err != nil {
+		respondError(c, service.NewValidationError(err), "Неверный формат ответа")
+		return "", false
+	}
+	return format, true
+}
+
+// Построчная выгрузка в CSV или JSONL. Заголовки ответа отправляются
+// с первой строкой, поэтому до неё ещё можно ответить ошибкой
+type exporter struct {
+	c        *gin.Context
+	format   exportFormat
+	filename string
+	columns  []string
+	csv      *csv.Writer
+	json     *json.Encoder
+	rows     int
+}
+
+func newExporter(c *gin.Context, format exportFormat, filename string, columns []string) *exporter {
+	return &exporter{c: c, format: format, filename: filename, columns: columns}
+}
+
+func (e *exporter) started() bool {
+	return e.csv != nil || e.json != nil
+}
+
+func (e *exporter) start() error {
+	w := e.c.Writer
+	if e.format == exportJSONL {
+		w.Header().Set("Content-Type", mimeJSONL+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename+".jsonl"))
+		w.WriteHeader(http.StatusOK)
+		e.json = json.NewEncoder(w)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", mimeCSV+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename+".csv"))
+	w.WriteHeader(http.StatusOK)
+
+	e.csv = csv.NewWriter(w)
+	if e.format == exportExcel {
+		if _, err := w.WriteString("\ufeff"); err != nil {
+			return err
+		}
+		e.csv.Comma = ';'
+		e.csv.UseCRLF = true
+	}
+	return e.csv.Write(e.columns)
+}
+
+// Запись одной строки: item — для JSONL, row — значения колонок для CSV
+func (e *exporter) write(item interface{}, row []string) error {
+	if !e.started() {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if e.json != nil {
+		err = e.json.Encode(item)
+	} else {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = escapeFormula(cell)
+		}
+		err = e.csv.Write(cells)
+	}
+	if err != nil {
+		return err
+	}
+
+	if e.rows++; e.rows%flushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// Завершение выгрузки; пустая выгрузка — только заголовок CSV
+func (e *exporter) close() error {
+	if !e.started() {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+// Завершение ответа. Пока ничего не отправлено, ошибка возвращается как problem+json;
+// после начала выгрузки остаётся только записать её в лог и оборвать ответ
+func (e *exporter) finish(err error, message string) {
+	if err == nil {
+		err = e.close()
+	}
+	if err == nil {
+		slog.Info("Выгрузка завершена", "file", e.filename, "rows", e.rows)
+		return
+	}
+
+	if !e.started() {
+		respondError(e.c, err, message)
+		return
+	}
+	slog.Error(message, "error", err)
+	e.c.Abort()
+}
+
+func (e *exporter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	e.c.Writer.Flush()
+	return nil
+}
+
+// Значение ячейки, которое Excel и другие табличные редакторы не примут за формулу:
+// текст, начинающийся с =, +, -, @ или управляющего символа, получает префикс «'».
+// Числа, в том числе отрицательные, остаются как есть
+func escapeFormula(cell string) string {
+	if cell == "" || !strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(strings.Replace(cell, ",", ".", 1), 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+func (e *exporter) int(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+func (e *exporter) float(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if e.format == exportExcel {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func (e *exporter) optional(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func (e *exporter) optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return e.int(int64(*v))
+}
+
+// Выгрузка списка подписок построчно из БД
+func (h *Handler) exportSubscriptions(c *gin.Context, format exportFormat, query models.ListQuery) {
+	e := newExporter(c, format, "subscriptions", []string{
+		"id", "service_name", "price", "currency", "billing_period", "user_id", "start_date", "end_date", "status",
+		"trial_months", "intro_price", "intro_months", "tags", "deleted_at",
+	})
+	err := h.service.ExportSubscriptions(query, func(dto models.SubscriptionDTO) error {
+		return e.write(dto, []string{
+			dto.ID, dto.ServiceName, e.int(int64(dto.Price)), dto.Currency, string(dto.BillingPeriod),
+			dto.UserID, dto.StartDate, e.optional(dto.EndDate), string(dto.Status),
+			e.int(int64(dto.TrialMonths)), e.optionalInt(dto.IntroPrice), e.int(int64(dto.IntroMonths)),
+			strings.Join(dto.Tags, " "), e.optional(dto.DeletedAt),
+		})
+	})
+	e.finish(err, "Не удалось выгрузить подписки")
+}
+
+// Выгрузка итогов агрегации: по подпискам (details), по группам или по валютам
+func exportAggregate(c *gin.Context, format exportFormat, filename string, result *models.AggregateResult) {
+	var e *exporter
+	var err error
+	switch {
+	case len(result.Subscriptions) > 0:
+		e = newExporter(c, format, filename, []string{"id", "service_name", "user_id", "currency", "months", "charges", "gross", "subtotal"})
+		for _, sub := range result.Subscriptions {
+			if err = e.write(sub, []string{
+				sub.ID, sub.ServiceName, sub.UserID, sub.Currency,
+				e.int(int64(sub.Months)), e.int(int64(sub.Charges)), e.int(sub.Gross), e.int(sub.Subtotal),
+			}); err != nil {
+				break
+			}
+		}
+	case len(result.Groups) > 0:
+		e = newExporter(c, format, filename, []string{"service_name", "category", "user_id", "tag", "currency", "months", "gross", "subtotal"})
+		for _, group := range result.Groups {
+			if err = e.write(group, []string{
+				group.ServiceName, group.Category, group.UserID, group.Tag, group.Currency, e.int(int64(group.Months)), e.int(group.Gross), e.int(group.Subtotal),
+			}); err != nil {
+				break
+			}
+		}
+	default:
+		e = newExporter(c, format, filename, []string{"currency", "total"})
+		for _, total := range result.Totals {
+			if err = e.write(total, []string{total.Currency, e.int(total.Total)}); err != nil {
+				break
+			}
+		}
+	}
+	e.finish(err, "Не удалось выгрузить итоги")
+}
+
+// Помесячные итоги: одна строка на сервис в каждом месяце
+func exportMonthly(c *gin.Context, format exportFormat, buckets []models.MonthlyBucket) {
+	type monthlyRow struct {
+		Month string `json:"month"`
+		models.ServiceTotal
+	}
+
+	e := newExporter(c, format, "monthly", []string{"month", "service_name", "currency", "total"})
+	var err error
+loop:
+	for _, bucket := range buckets {
+		for _, total := range bucket.Services {
+			row := monthlyRow{Month: bucket.Month, ServiceTotal: total}
+			if err = e.write(row, []string{bucket.Month, total.ServiceName, total.Currency, e.int(total.Total)}); err != nil {
+				break loop
+			}
+		}
+	}
+	e.finish(err, "Не удалось выгрузить помесячные итоги")
+}
+
+func exportNormalized(c *gin.Context, format exportFormat, costs []models.NormalizedCost) {
+	e := newExporter(c, format, "normalized", []string{
+		"id", "service_name", "user_id", "currency", "billing_period", "price", "monthly_cost",
+	})
+	var err error
+	for _, cost := range costs {
+		if err = e.write(cost, []string{
+			cost.ID, cost.ServiceName, cost.UserID, cost.Currency, string(cost.BillingPeriod),
+			e.int(int64(cost.Price)), e.float(cost.MonthlyCost),
+		}); err != nil {
+			break
+		}
+	}
+	e.finish(err, "Не удалось выгрузить месячную стоимость")
+}
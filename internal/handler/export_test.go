@@ -0,0 +1,27 @@
+package handler
+
+import "testing"
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{cell: "", want: ""},
+		{cell: "Netflix", want: "Netflix"},
+		{cell: "=HYPERLINK(\"http://example.com\")", want: "'=HYPERLINK(\"http://example.com\")"},
+		{cell: "+7 999", want: "'+7 999"},
+		{cell: "-cmd", want: "'-cmd"},
+		{cell: "@SUM(A1:A2)", want: "'@SUM(A1:A2)"},
+		{cell: "\t=1+1", want: "'\t=1+1"},
+		{cell: "-150", want: "-150"},
+		{cell: "-12,5", want: "-12,5"},
+		{cell: "team:backend", want: "team:backend"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFormula(tt.cell); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+	}
+}
@@ -3,9 +3,14 @@ package handler
 import (
 	"aggregationSubscriptions/internal/models"
 	"aggregationSubscriptions/internal/service"
+	"aggregationSubscriptions/internal/utils"
+	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type Handler struct {
@@ -17,23 +22,58 @@ func NewHandler(service service.Service) *Handler {
 }
 
 // GetSubscriptions godoc
-// @Summary      Получить все подписки
-// @Description  Возвращает список всех подписок пользователей
+// @Summary      Получить список подписок
+// @Description  Возвращает страницу подписок пользователей с фильтрами, сортировкой и общим числом записей.
+// @Description  В форматах csv, excel и jsonl (параметр format или заголовок Accept) записи выгружаются построчно; без limit — все подходящие
 // @Tags         subscriptions
 // @Produce      json
-// @Success      200  {object}  map[string][]models.SubscriptionDTO
-// @Failure      500  {object}  map[string]string
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        user_id       query     string  false  "ID пользователя"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        tag           query     []string  false  "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test"  collectionFormat(multi)
+// @Param        active_at     query     string  false  "Действующие в месяце (MM-YYYY) или в день (YYYY-MM-DD)"
+// @Param        min_price     query     int     false  "Минимальная цена"
+// @Param        max_price     query     int     false  "Максимальная цена"
+// @Param        sort          query     string  false  "Колонки сортировки через запятую, минус — по убыванию, например -price,service_name"
+// @Param        limit         query     int     false  "Размер страницы (1–1000, по умолчанию 50)"
+// @Param        offset        query     int     false  "Смещение от начала выборки"
+// @Param        include_deleted  query  bool    false  "Включить мягко удалённые подписки"
+// @Param        format        query     string  false  "Формат ответа: json, csv, excel (CSV для Excel) или jsonl"
+// @Success      200  {object}  models.SubscriptionPage
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscriptions [get]
 func (h *Handler) GetSubscriptions(c *gin.Context) {
-	subs, err := h.service.GetAllSubscriptions()
+	query, err := utils.ParseListQuery(c.Request.URL.Query())
+	if err != nil {
+		slog.Error("Неверные параметры списка подписок", "error", err)
+		respondError(c, service.NewValidationError(err), "Неверные параметры списка подписок")
+		return
+	}
+
+	format, ok := requestFormat(c)
+	if !ok {
+		return
+	}
+	if format != exportJSON {
+		// Выгрузка не постраничная, если limit не задан явно
+		if c.Query("limit") == "" {
+			query.Limit = 0
+		}
+		h.exportSubscriptions(c, format, query)
+		return
+	}
+
+	page, err := h.service.GetAllSubscriptions(query)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось найти записи подписок"})
+		respondError(c, err, "Не удалось найти записи подписок")
 		return
 	}
 
 	slog.Info("Записи были успешно получены")
-	c.JSON(http.StatusOK, gin.H{"data": subs})
+	c.JSON(http.StatusOK, page)
 }
 
 // GetSubscription godoc
@@ -43,59 +83,78 @@ func (h *Handler) GetSubscriptions(c *gin.Context) {
 // @Produce      json
 // @Param        id   path      string  true  "ID подписки"
 // @Success      200  {object}  map[string]models.SubscriptionDTO
-// @Failure      404  {object}  map[string]string
+// @Header       200  {string}  ETag  "Версия подписки; передаётся в If-Match при изменении и удалении"
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscriptions/{id} [get]
 func (h *Handler) GetSubscription(c *gin.Context) {
 	sub, err := h.service.GetSubscriptionByID(c.Param("id"))
 
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err, "Не удалось получить запись")
 		return
 	}
 
 	slog.Info("Запись была успешно получена")
+	setETag(c, sub.Version)
 	c.JSON(http.StatusOK, gin.H{"data": sub})
 }
 
 // CreateSubscription godoc
 // @Summary      Создать новую подписку
-// @Description  Добавляет новую подписку в систему
+// @Description  Добавляет новую подписку в систему и возвращает сохранённую запись. Название сервиса из справочника приводится к каноническому, а без цены подставляется цена по умолчанию из справочника
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        subscription  body      models.SubscriptionDTO  true  "Данные подписки"
-// @Success      200  {object}  map[string]string
-// @Failure      400  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      201  {object}  map[string]models.SubscriptionDTO
+// @Header       201  {string}  Location  "Адрес созданной подписки: /subscription/{id}"
+// @Header       201  {string}  ETag      "Версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscription [post]
 func (h *Handler) CreateSubscription(c *gin.Context) {
 	var dto models.SubscriptionDTO
 	if err := c.ShouldBindJSON(&dto); err != nil {
 		slog.Error("Ошибка записи данных", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка записи данных"})
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
 		return
 	}
 
-	if err := h.service.CreateNewSubscription(dto); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	created, err := h.service.CreateNewSubscription(dto, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось создать запись")
 		return
 	}
 
 	slog.Info("Запись была успешно создана")
-	c.JSON(http.StatusOK, gin.H{"data": "OK"})
+	c.Header("Location", "/subscription/"+created.ID)
+	setETag(c, created.Version)
+	c.JSON(http.StatusCreated, gin.H{"data": created})
 }
 
 // UpdateSubscription godoc
 // @Summary      Обновить подписку
-// @Description  Изменяет данные существующей подписки
+// @Description  Изменяет данные существующей подписки. Новая цена действует с месяца price_effective_from (по умолчанию текущего), прошлые месяцы считаются по прежней цене. Требует If-Match с ETag текущей версии
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
 // @Param        subscription  body  models.SubscriptionDTO  true  "Обновленные данные подписки"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
 // @Success      200  {object}  map[string]models.SubscriptionDTO
-// @Failure      400  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Header       200  {string}  ETag  "Новая версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscription/{id} [put]
 func (h *Handler) UpdateSubscription(c *gin.Context) {
 	id := c.Param("id")
@@ -103,36 +162,106 @@ func (h *Handler) UpdateSubscription(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&dto); err != nil {
 		slog.Error("Ошибка записи данных", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ошибка записи данных"})
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
-	updated, err := h.service.UpdateSubscription(id, dto)
+	updated, err := h.service.UpdateSubscription(id, version, dto, actor(c))
 	if err != nil {
 		slog.Error("Не удалось сохранить запись", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить запись"})
+		respondError(c, err, "Не удалось сохранить запись")
+		return
+	}
+
+	slog.Info("Запись была успешно изменена")
+	setETag(c, updated.Version)
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// PatchSubscription godoc
+// @Summary      Частично обновить подписку
+// @Description  Применяет JSON Merge Patch (RFC 7396): переданные поля заменяются, null удаляет необязательное поле (например, end_date). Проверяется итоговая запись, сохраняются только изменённые поля. Требует If-Match с ETag текущей версии
+// @Tags         subscriptions
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        id     path  string                  true  "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        patch  body  models.SubscriptionDTO  true  "Изменяемые поля подписки"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.SubscriptionDTO
+// @Header       200  {string}  ETag  "Новая версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      415  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id} [patch]
+func (h *Handler) PatchSubscription(c *gin.Context) {
+	switch c.ContentType() {
+	case "application/merge-patch+json", "application/json":
+	default:
+		writeProblem(c, http.StatusUnsupportedMediaType, problemBadRequest, "Ожидается application/merge-patch+json", nil)
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil || !json.Valid(patch) {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	updated, err := h.service.PatchSubscription(c.Param("id"), version, patch, actor(c))
+	if err != nil {
+		slog.Error("Не удалось изменить запись", "error", err)
+		respondError(c, err, "Не удалось изменить запись")
 		return
 	}
 
 	slog.Info("Запись была успешно изменена")
+	setETag(c, updated.Version)
 	c.JSON(http.StatusOK, gin.H{"data": updated})
 }
 
 // DeleteSubscription godoc
 // @Summary      Удалить подписку
-// @Description  Удаляет подписку по ID
+// @Description  Помечает подписку удалённой (её можно восстановить до очистки). Требует If-Match с ETag текущей версии
 // @Tags         subscriptions
 // @Produce      json
 // @Param        id   path      string  true  "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
 // @Success      200  {object}  map[string]string
-// @Failure      500  {object}  map[string]string
+// @Failure      404  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscription/{id} [delete]
 func (h *Handler) DeleteSubscription(c *gin.Context) {
-	err := h.service.DeleteSubscription(c.Param("id"))
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	err := h.service.DeleteSubscription(c.Param("id"), version, actor(c))
 
 	if err != nil {
 		slog.Error("Не удалось удалить запись", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить запись"})
+		respondError(c, err, "Не удалось удалить запись")
 		return
 	}
 
@@ -140,31 +269,442 @@ func (h *Handler) DeleteSubscription(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": "OK"})
 }
 
+// RestoreSubscription godoc
+// @Summary      Восстановить удалённую подписку
+// @Description  Снимает пометку удаления с подписки; для действующей подписки возвращает её без изменений. Требует If-Match с ETag текущей версии (удаление увеличивает версию на единицу)
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      string  true  "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.SubscriptionDTO
+// @Header       200  {string}  ETag  "Версия подписки"
+// @Failure      404  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/restore [post]
+func (h *Handler) RestoreSubscription(c *gin.Context) {
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	restored, err := h.service.RestoreSubscription(c.Param("id"), version, actor(c))
+	if err != nil {
+		slog.Error("Не удалось восстановить запись", "error", err)
+		respondError(c, err, "Не удалось восстановить запись")
+		return
+	}
+
+	slog.Info("Запись была успешно восстановлена")
+	setETag(c, restored.Version)
+	c.JSON(http.StatusOK, gin.H{"data": restored})
+}
+
+// PurgeDeletedSubscriptions godoc
+// @Summary      Очистить удалённые подписки
+// @Description  Окончательно удаляет подписки, помеченные удалёнными раньше, чем retention_days дней назад
+// @Tags         admin
+// @Produce      json
+// @Param        retention_days  query     int  false  "Срок хранения удалённых подписок в днях (по умолчанию 30)"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.PurgeResult
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /admin/subscriptions/purge [post]
+func (h *Handler) PurgeDeletedSubscriptions(c *gin.Context) {
+	var retentionDays int
+	if v := c.Query("retention_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			respondError(c, service.NewValidationError(models.FieldError{Field: "retention_days", Message: "должен быть целым числом > 0"}), "Неверный срок хранения")
+			return
+		}
+		retentionDays = days
+	}
+
+	result, err := h.service.PurgeDeletedSubscriptions(retentionDays, actor(c))
+	if err != nil {
+		slog.Error("Не удалось очистить удалённые записи", "error", err)
+		respondError(c, err, "Не удалось очистить удалённые записи")
+		return
+	}
+
+	slog.Info("Удалённые записи очищены", "purged", result.Purged)
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ImportSubscriptions godoc
+// @Summary      Массовый импорт подписок
+// @Description  Создаёт подписки из CSV (первая строка — заголовок с названиями полей) или JSON Lines (одна подписка на строку). Каждая строка проверяется как в POST /subscription. В режиме atomic при ошибке хотя бы в одной строке не сохраняется ничего, в режиме best_effort сохраняются все корректные строки. Возвращает результат по каждой строке
+// @Tags         subscriptions
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        mode     query   string  false  "atomic (по умолчанию) или best_effort"
+// @Param        format   query   string  false  "csv или jsonl; по умолчанию определяется по Content-Type"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.ImportReport
+// @Failure      400  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/import [post]
+func (h *Handler) ImportSubscriptions(c *gin.Context) {
+	mode, err := utils.ParseImportMode(c.Query("mode"))
+	if err != nil {
+		respondError(c, service.NewValidationError(err), "Неверный режим импорта")
+		return
+	}
+	format, err := utils.ParseImportFormat(c.Query("format"), c.ContentType())
+	if err != nil {
+		respondError(c, service.NewValidationError(err), "Неверный формат импорта")
+		return
+	}
+
+	records, err := utils.DecodeImport(c.Request.Body, format)
+	if err != nil {
+		slog.Error("Не удалось разобрать файл импорта", "error", err)
+		var fieldErr models.FieldError
+		if errors.As(err, &fieldErr) {
+			respondError(c, service.NewValidationError(err), "Не удалось разобрать файл импорта")
+			return
+		}
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, err.Error(), nil)
+		return
+	}
+
+	report, err := h.service.ImportSubscriptions(records, mode, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось импортировать подписки")
+		return
+	}
+
+	slog.Info("Импорт подписок завершён", "created", report.Created, "failed", report.Failed)
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// GetSubscriptionPrices godoc
+// @Summary      Получить историю цен подписки
+// @Description  Возвращает цены подписки с месяцами, с которых они действуют. Стоимость за прошлые месяцы считается по действовавшей тогда цене
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path      string  true  "ID подписки"
+// @Success      200  {object}  map[string][]models.PricePoint
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/prices [get]
+func (h *Handler) GetSubscriptionPrices(c *gin.Context) {
+	prices, err := h.service.GetSubscriptionPrices(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить историю цен")
+		return
+	}
+
+	slog.Info("История цен успешно получена")
+	c.JSON(http.StatusOK, gin.H{"data": prices})
+}
+
+// GetRenewalsCalendar godoc
+// @Summary      Календарь продлений подписок пользователя
+// @Description  Возвращает календарь iCalendar (RFC 5545) с повторяющимся событием на каждую действующую или будущую подписку. Повторения следуют периодичности списания, начинаются с первого платного списания после пробного периода и заканчиваются с окончанием подписки
+// @Tags         subscriptions
+// @Produce      text/calendar
+// @Param        user_id  path      string  true  "ID пользователя"
+// @Success      200  {string}  string  "Календарь в формате iCalendar"
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /users/{user_id}/renewals.ics [get]
+func (h *Handler) GetRenewalsCalendar(c *gin.Context) {
+	calendar, err := h.service.GetRenewalsCalendar(c.Param("user_id"))
+	if err != nil {
+		respondError(c, err, "Не удалось сформировать календарь продлений")
+		return
+	}
+
+	slog.Info("Календарь продлений успешно сформирован")
+	c.Header("Content-Disposition", `inline; filename="renewals.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar)
+}
+
+// GetSubscriptionHistory godoc
+// @Summary      Получить историю изменений подписки
+// @Description  Возвращает изменения подписки от старых к новым: действие, время, автора и состояние до и после. История сохраняется и после удаления подписки
+// @Tags         history
+// @Produce      json
+// @Param        id   path      string  true  "ID подписки"
+// @Success      200  {object}  map[string][]models.SubscriptionChange
+// @Failure      404  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/history [get]
+func (h *Handler) GetSubscriptionHistory(c *gin.Context) {
+	changes, err := h.service.GetSubscriptionHistory(c.Param("id"))
+	if err != nil {
+		respondError(c, err, "Не удалось получить историю изменений")
+		return
+	}
+
+	slog.Info("История изменений успешно получена")
+	c.JSON(http.StatusOK, gin.H{"data": changes})
+}
+
+// GetChanges godoc
+// @Summary      Получить ленту изменений подписок
+// @Description  Возвращает изменения всех подписок за интервал времени [from, to) от старых к новым
+// @Tags         history
+// @Produce      json
+// @Param        from    query     string  false  "Начало интервала (RFC 3339 или YYYY-MM-DD)"
+// @Param        to      query     string  false  "Конец интервала, не включая (RFC 3339 или YYYY-MM-DD)"
+// @Param        limit   query     int     false  "Размер страницы (1–1000, по умолчанию 50)"
+// @Param        offset  query     int     false  "Смещение от начала выборки"
+// @Success      200  {object}  models.ChangePage
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/changes [get]
+func (h *Handler) GetChanges(c *gin.Context) {
+	query, err := utils.ParseChangeQuery(c.Request.URL.Query())
+	if err != nil {
+		slog.Error("Неверные параметры ленты изменений", "error", err)
+		respondError(c, service.NewValidationError(err), "Неверные параметры ленты изменений")
+		return
+	}
+
+	page, err := h.service.GetChanges(query)
+	if err != nil {
+		respondError(c, err, "Не удалось получить ленту изменений")
+		return
+	}
+
+	slog.Info("Лента изменений успешно получена")
+	c.JSON(http.StatusOK, page)
+}
+
 // GetSubscriptionsPrice godoc
 // @Summary      Получить общую стоимость подписок
 // @Description  Возвращает итоговую стоимость всех подписок по фильтрам
 // @Tags         subscriptions
 // @Produce      json
+// @Produce      text/csv
+// @Produce      application/x-ndjson
 // @Param        user_id       query     string  false  "ID пользователя"
-// @Param        service_name  query     string  false  "Название подписки"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        tag           query     []string  false  "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test"  collectionFormat(multi)
 // @Param        start_date    query     string  true   "Дата начала периода"
 // @Param        end_date      query     string  true   "Дата конца периода"
-// @Success      200  {object}  map[string]int64
-// @Failure      400  {object}  map[string]string
+// @Param        convert_to    query     string  false  "Валюта, в которую пересчитываются суммы (ISO 4217)"
+// @Param        include_deleted  query  bool    false  "Учитывать мягко удалённые подписки"
+// @Param        details       query     bool    false  "Добавить число учтённых месяцев по каждой подписке"
+// @Param        format        query     string  false  "Формат ответа: json, csv, excel (CSV для Excel) или jsonl"
+// @Success      200  {object}  models.AggregateResult
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
 // @Router       /subscriptions/aggregate/total [get]
 func (h *Handler) GetSubscriptionsPrice(c *gin.Context) {
-	userID := c.Query("user_id")
-	serviceName := c.Query("service_name")
-	startStr := c.Query("start_date")
-	endStr := c.Query("end_date")
+	format, ok := requestFormat(c)
+	if !ok {
+		return
+	}
 
-	total, err := h.service.GetSubscriptionsPrice(userID, serviceName, startStr, endStr)
+	result, err := h.service.GetSubscriptionsPrice(aggregateQuery(c))
 	if err != nil {
 		slog.Error("Не удалось рассчитать итоговую цену", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err, "Не удалось рассчитать итоговую цену")
+		return
+	}
+	if format != exportJSON {
+		exportAggregate(c, format, "total", result)
 		return
 	}
 
 	slog.Info("Итоговая цена успешно получена")
-	c.JSON(http.StatusOK, gin.H{"total_price": total})
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSubscriptionsPriceBreakdown godoc
+// @Summary      Получить стоимость подписок с разбивкой по группам
+// @Description  Возвращает итоговую стоимость за период и промежуточные итоги по названию подписки, её категории, пользователю и/или значению тега: gross — без скидок, subtotal — с их учётом. Сервисы вне справочника попадают в категорию uncategorized
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        user_id       query     string  false  "ID пользователя"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        tag           query     []string  false  "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test"  collectionFormat(multi)
+// @Param        start_date    query     string  true   "Дата начала периода"
+// @Param        end_date      query     string  true   "Дата конца периода"
+// @Param        convert_to    query     string  false  "Валюта, в которую пересчитываются суммы (ISO 4217)"
+// @Param        include_deleted  query  bool    false  "Учитывать мягко удалённые подписки"
+// @Param        group_by      query     string  true   "Измерения группировки через запятую: service_name, user_id, category (категория из справочника сервисов), tag:<ключ> (значение тега, не больше одного ключа)"
+// @Param        details       query     bool    false  "Добавить число учтённых месяцев по каждой подписке"
+// @Param        format        query     string  false  "Формат ответа: json, csv, excel (CSV для Excel) или jsonl"
+// @Success      200  {object}  models.AggregateResult
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/aggregate/breakdown [get]
+func (h *Handler) GetSubscriptionsPriceBreakdown(c *gin.Context) {
+	format, ok := requestFormat(c)
+	if !ok {
+		return
+	}
+	query := aggregateQuery(c)
+
+	groupBy, err := utils.ParseGroupBy(c.Query("group_by"))
+	if err != nil {
+		slog.Error("Неверный параметр группировки", "error", err)
+		respondError(c, service.NewValidationError(err), "Неверный параметр группировки")
+		return
+	}
+	query.GroupBy = groupBy
+
+	result, err := h.service.GetSubscriptionsPrice(query)
+	if err != nil {
+		slog.Error("Не удалось рассчитать стоимость по группам", "error", err)
+		respondError(c, err, "Не удалось рассчитать стоимость по группам")
+		return
+	}
+	if format != exportJSON {
+		exportAggregate(c, format, "breakdown", result)
+		return
+	}
+
+	slog.Info("Стоимость по группам успешно получена")
+	c.JSON(http.StatusOK, result)
+}
+
+// GetSubscriptionsPriceByMonth godoc
+// @Summary      Получить помесячную стоимость подписок
+// @Description  Возвращает по одной записи на каждый календарный месяц периода: сумму, число активных подписок и разбивку по сервисам
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        user_id       query     string  false  "ID пользователя"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        tag           query     []string  false  "Фильтр по тегам: запятая — И, | — ИЛИ, ! — отрицание, например team:backend|team:frontend,!env:test"  collectionFormat(multi)
+// @Param        start_date    query     string  true   "Дата начала периода"
+// @Param        end_date      query     string  true   "Дата конца периода"
+// @Param        convert_to    query     string  false  "Валюта, в которую пересчитываются суммы (ISO 4217)"
+// @Param        include_deleted  query  bool    false  "Учитывать мягко удалённые подписки"
+// @Param        format        query     string  false  "Формат ответа: json, csv, excel (CSV для Excel) или jsonl"
+// @Success      200  {object}  map[string][]models.MonthlyBucket
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/aggregate/monthly [get]
+func (h *Handler) GetSubscriptionsPriceByMonth(c *gin.Context) {
+	format, ok := requestFormat(c)
+	if !ok {
+		return
+	}
+
+	buckets, err := h.service.GetSubscriptionsPriceByMonth(aggregateQuery(c))
+	if err != nil {
+		slog.Error("Не удалось рассчитать помесячную стоимость", "error", err)
+		respondError(c, err, "Не удалось рассчитать помесячную стоимость")
+		return
+	}
+	if format != exportJSON {
+		exportMonthly(c, format, buckets)
+		return
+	}
+
+	slog.Info("Помесячная стоимость успешно получена")
+	c.JSON(http.StatusOK, gin.H{"data": buckets})
+}
+
+// GetNormalizedMonthlyCosts godoc
+// @Summary      Получить стоимость подписок, приведённую к месяцу
+// @Description  Возвращает подписки, активные в указанном месяце, со стоимостью, пересчитанной на один месяц независимо от периодичности списания
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Param        user_id       query     string  false  "ID пользователя"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        month         query     string  false  "Месяц в формате MM-YYYY, по умолчанию текущий"
+// @Param        format        query     string  false  "Формат ответа: json, csv, excel (CSV для Excel) или jsonl"
+// @Success      200  {object}  map[string][]models.NormalizedCost
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/aggregate/normalized [get]
+func (h *Handler) GetNormalizedMonthlyCosts(c *gin.Context) {
+	format, ok := requestFormat(c)
+	if !ok {
+		return
+	}
+
+	costs, err := h.service.GetNormalizedMonthlyCosts(c.Query("user_id"), c.Query("service_name"), c.Query("month"))
+	if err != nil {
+		slog.Error("Не удалось рассчитать месячную стоимость подписок", "error", err)
+		respondError(c, err, "Не удалось рассчитать месячную стоимость подписок")
+		return
+	}
+	if format != exportJSON {
+		exportNormalized(c, format, costs)
+		return
+	}
+
+	slog.Info("Месячная стоимость подписок успешно получена")
+	c.JSON(http.StatusOK, gin.H{"data": costs})
+}
+
+// GetForecast godoc
+// @Summary      Прогноз расходов пользователя
+// @Description  Проецирует расходы пользователя на текущий и следующие месяцы: итог, разбивка по сервисам и по каждому месяцу. Бессрочные подписки считаются продолжающимися, запланированные окончания и будущие начала учитываются
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id       path      string  true   "ID пользователя"
+// @Param        months        query     int     false  "Горизонт прогноза в месяцах (1–60, по умолчанию 12)"
+// @Param        service_name  query     string  false  "Название подписки или его псевдоним из справочника"
+// @Param        convert_to    query     string  false  "Валюта, в которую пересчитываются суммы (ISO 4217)"
+// @Success      200  {object}  map[string]models.Forecast
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /users/{user_id}/forecast [get]
+func (h *Handler) GetForecast(c *gin.Context) {
+	query := models.ForecastQuery{
+		UserID:      c.Param("user_id"),
+		ServiceName: c.Query("service_name"),
+		ConvertTo:   c.Query("convert_to"),
+	}
+	if v := c.Query("months"); v != "" {
+		months, err := strconv.Atoi(v)
+		if err != nil || months <= 0 {
+			respondError(c, service.NewValidationError(models.FieldError{Field: "months", Message: "должен быть целым числом > 0"}), "Неверный горизонт прогноза")
+			return
+		}
+		query.Months = months
+	}
+
+	forecast, err := h.service.GetForecast(query)
+	if err != nil {
+		slog.Error("Не удалось построить прогноз расходов", "error", err)
+		respondError(c, err, "Не удалось построить прогноз расходов")
+		return
+	}
+
+	slog.Info("Прогноз расходов успешно построен")
+	c.JSON(http.StatusOK, gin.H{"data": forecast})
+}
+
+// Общие параметры запросов агрегации
+func aggregateQuery(c *gin.Context) models.AggregateQuery {
+	return models.AggregateQuery{
+		UserID:      c.Query("user_id"),
+		ServiceName: c.Query("service_name"),
+		StartDate:   c.Query("start_date"),
+		EndDate:     c.Query("end_date"),
+		Details:     c.Query("details") == "true",
+		ConvertTo:   c.Query("convert_to"),
+		Tags:        c.QueryArray("tag"),
+
+		IncludeDeleted: c.Query("include_deleted") == "true",
+	}
+}
+
+// Автор изменения из заголовка X-Actor; без заголовка — анонимный
+func actor(c *gin.Context) string {
+	if name := strings.TrimSpace(c.GetHeader("X-Actor")); name != "" {
+		return name
+	}
+	return service.AnonymousActor
 }
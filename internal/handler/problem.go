@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Типы ошибок (поле type из RFC 7807)
+const (
+	problemBadRequest = "/problems/bad-request"
+	problemValidation = "/problems/validation-error"
+	problemNotFound   = "/problems/not-found"
+	problemConflict   = "/problems/conflict"
+	problemInternal   = "about:blank"
+
+	problemPreconditionFailed   = "/problems/precondition-failed"
+	problemPreconditionRequired = "/problems/precondition-required"
+)
+
+// Ответ об ошибке в формате application/problem+json
+func writeProblem(c *gin.Context, status int, problemType, detail string, fields []models.FieldError) {
+	c.Header("Content-Type", problemContentType)
+	c.JSON(status, models.Problem{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Errors:   fields,
+	})
+}
+
+// Ответ на ошибку сервиса: HTTP-статус определяется типом ошибки.
+// Для непредвиденных ошибок клиенту отдаётся только message
+func respondError(c *gin.Context, err error, message string) {
+	var validationErr *service.ValidationError
+
+	switch {
+	case errors.As(err, &validationErr):
+		writeProblem(c, http.StatusUnprocessableEntity, problemValidation, "Данные не прошли проверку", validationErr.Fields)
+	case errors.Is(err, service.ErrNotFound), errors.Is(err, service.ErrBudgetNotFound),
+		errors.Is(err, service.ErrDiscountNotFound), errors.Is(err, service.ErrCatalogNotFound):
+		writeProblem(c, http.StatusNotFound, problemNotFound, err.Error(), nil)
+	case errors.Is(err, service.ErrConflict), errors.Is(err, service.ErrInvalidTransition),
+		errors.Is(err, service.ErrCatalogConflict):
+		writeProblem(c, http.StatusConflict, problemConflict, err.Error(), nil)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeProblem(c, http.StatusPreconditionFailed, problemPreconditionFailed, err.Error(), nil)
+	default:
+		slog.Error(message, "error", err)
+		writeProblem(c, http.StatusInternalServerError, problemInternal, message, nil)
+	}
+}
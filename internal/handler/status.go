@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/service"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// PauseSubscription godoc
+// @Summary      Приостановить подписку
+// @Description  Переводит подписку из trial или active в paused с месяца from (по умолчанию текущего). Месяцы паузы не учитываются в итогах. Требует If-Match с ETag текущей версии
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id      path  string                  true   "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        change  body  models.StatusChangeDTO  false  "Месяц начала паузы"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.SubscriptionDTO
+// @Header       200  {string}  ETag  "Версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/pause [post]
+func (h *Handler) PauseSubscription(c *gin.Context) {
+	change, ok := statusChange(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.PauseSubscription(c.Param("id"), version, change, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось приостановить подписку")
+		return
+	}
+
+	slog.Info("Подписка приостановлена")
+	setETag(c, sub.Version)
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+// ResumeSubscription godoc
+// @Summary      Возобновить подписку
+// @Description  Переводит приостановленную подписку в active с месяца from (по умолчанию текущего). Требует If-Match с ETag текущей версии
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id      path  string                  true   "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        change  body  models.StatusChangeDTO  false  "Месяц возобновления"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.SubscriptionDTO
+// @Header       200  {string}  ETag  "Версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/resume [post]
+func (h *Handler) ResumeSubscription(c *gin.Context) {
+	change, ok := statusChange(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.ResumeSubscription(c.Param("id"), version, change, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось возобновить подписку")
+		return
+	}
+
+	slog.Info("Подписка возобновлена")
+	setETag(c, sub.Version)
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+// CancelSubscription godoc
+// @Summary      Отменить подписку
+// @Description  Переводит подписку в cancelled и задаёт дату окончания end_date (по умолчанию — текущий месяц или, для дат с точностью до дня, текущий день). Отменённую подписку нельзя возобновить. Требует If-Match с ETag текущей версии
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id      path  string                  true   "ID подписки"
+// @Param        If-Match  header  string  true  "ETag текущей версии подписки или *"
+// @Param        change  body  models.StatusChangeDTO  false  "Дата окончания"
+// @Param        X-Actor  header  string  false  "Кто выполняет изменение (для истории изменений)"
+// @Success      200  {object}  map[string]models.SubscriptionDTO
+// @Header       200  {string}  ETag  "Версия подписки"
+// @Failure      400  {object}  models.Problem
+// @Failure      404  {object}  models.Problem
+// @Failure      409  {object}  models.Problem
+// @Failure      412  {object}  models.Problem
+// @Failure      422  {object}  models.Problem
+// @Failure      428  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscription/{id}/cancel [post]
+func (h *Handler) CancelSubscription(c *gin.Context) {
+	change, ok := statusChange(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.service.CancelSubscription(c.Param("id"), version, change, actor(c))
+	if err != nil {
+		respondError(c, err, "Не удалось отменить подписку")
+		return
+	}
+
+	slog.Info("Подписка отменена")
+	setETag(c, sub.Version)
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+// GetTrialEndings godoc
+// @Summary      Окончания пробных периодов
+// @Description  Возвращает подписки, у которых первое платное списание после пробного периода приходится на ближайшие within_days дней, — чтобы заранее предупредить пользователя. Сумма списания учитывает вводную цену и скидки
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id      query     string  false  "ID пользователя"
+// @Param        within_days  query     int     false  "Сколько дней вперёд смотреть (1–365, по умолчанию 30)"
+// @Success      200  {object}  map[string][]models.TrialEnding
+// @Failure      422  {object}  models.Problem
+// @Failure      500  {object}  models.Problem
+// @Router       /subscriptions/trials [get]
+func (h *Handler) GetTrialEndings(c *gin.Context) {
+	var withinDays int
+	if v := c.Query("within_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			respondError(c, service.NewValidationError(models.FieldError{Field: "within_days", Message: "должен быть целым числом > 0"}), "Неверный период поиска")
+			return
+		}
+		withinDays = days
+	}
+
+	endings, err := h.service.GetTrialEndings(c.Query("user_id"), withinDays)
+	if err != nil {
+		respondError(c, err, "Не удалось получить окончания пробных периодов")
+		return
+	}
+
+	slog.Info("Окончания пробных периодов успешно получены")
+	c.JSON(http.StatusOK, gin.H{"data": endings})
+}
+
+// Необязательное тело запроса смены статуса
+func statusChange(c *gin.Context) (models.StatusChangeDTO, bool) {
+	var change models.StatusChangeDTO
+	if err := c.ShouldBindJSON(&change); err != nil && !errors.Is(err, io.EOF) {
+		slog.Error("Ошибка записи данных", "error", err)
+		writeProblem(c, http.StatusBadRequest, problemBadRequest, "Ошибка записи данных", nil)
+		return change, false
+	}
+	return change, true
+}
@@ -0,0 +1,117 @@
+package models
+
+// Измерение, по которому группируется итоговая стоимость
+type GroupDimension string
+
+const (
+	GroupByServiceName GroupDimension = "service_name"
+	GroupByUserID      GroupDimension = "user_id"
+	// Категория сервиса из справочника
+	GroupByCategory GroupDimension = "category"
+)
+
+// Параметры запроса агрегации стоимости
+type AggregateQuery struct {
+	UserID      string
+	ServiceName string
+	StartDate   string
+	EndDate     string
+	GroupBy     []GroupDimension
+	Details     bool
+	// Валюта, в которую пересчитываются все суммы; пустая — без пересчёта
+	ConvertTo string
+	// Учитывать мягко удалённые подписки
+	IncludeDeleted bool
+	// Выражения фильтра по тегам (параметры tag); разбираются utils.ParseTagFilter
+	Tags []string
+}
+
+// Итог по одной группе подписок
+type AggregateGroup struct {
+	ServiceName string `json:"service_name,omitempty"`
+	Category    string `json:"category,omitempty"`
+	UserID      string `json:"user_id,omitempty"`
+	Currency    string `json:"currency"`
+	Months      int    `json:"months"`
+	// Тег с ключом группировки ("team:backend"); пусто у подписок без такого тега
+	Tag string `json:"tag,omitempty"`
+	// Сумма без скидок; Subtotal — с учётом скидок
+	Gross    int64 `json:"gross"`
+	Subtotal int64 `json:"subtotal"`
+}
+
+// Итог по одной подписке: число месяцев, пересекающихся с периодом,
+// и число списаний, попавших в период
+type SubscriptionTotal struct {
+	ID          string `json:"id"`
+	ServiceName string `json:"service_name"`
+	UserID      string `json:"user_id"`
+	Currency    string `json:"currency"`
+	Months      int    `json:"months"`
+	Charges     int    `json:"charges"`
+	// Сумма без скидок; Subtotal — с учётом скидок
+	Gross    int64 `json:"gross"`
+	Subtotal int64 `json:"subtotal"`
+}
+
+// Сумма в одной валюте
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Total    int64  `json:"total"`
+}
+
+// Результат агрегации стоимости за период.
+// TotalPrice заполняется, только если все суммы в одной валюте
+type AggregateResult struct {
+	TotalPrice    *int64              `json:"total_price,omitempty"`
+	Totals        []CurrencyTotal     `json:"totals"`
+	Groups        []AggregateGroup    `json:"groups,omitempty"`
+	Subscriptions []SubscriptionTotal `json:"subscriptions,omitempty"`
+}
+
+// Сумма по одному сервису
+type ServiceTotal struct {
+	ServiceName string `json:"service_name"`
+	Currency    string `json:"currency"`
+	Total       int64  `json:"total"`
+}
+
+// Итог за один календарный месяц
+type MonthlyBucket struct {
+	Month               string          `json:"month"`
+	TotalPrice          *int64          `json:"total_price,omitempty"`
+	Totals              []CurrencyTotal `json:"totals"`
+	ActiveSubscriptions int             `json:"active_subscriptions"`
+	Services            []ServiceTotal  `json:"services"`
+}
+
+// Стоимость подписки, приведённая к одному месяцу
+type NormalizedCost struct {
+	ID            string        `json:"id"`
+	ServiceName   string        `json:"service_name"`
+	UserID        string        `json:"user_id"`
+	Currency      string        `json:"currency"`
+	BillingPeriod BillingPeriod `json:"billing_period"`
+	Price         int           `json:"price"`
+	MonthlyCost   float64       `json:"monthly_cost"`
+}
+
+// Параметры прогноза расходов пользователя
+type ForecastQuery struct {
+	UserID      string
+	ServiceName string
+	// Горизонт прогноза в месяцах, начиная с текущего; 0 — по умолчанию
+	Months    int
+	ConvertTo string
+}
+
+// Прогноз расходов: итоги за весь горизонт, по сервисам и по каждому месяцу
+type Forecast struct {
+	UserID     string          `json:"user_id"`
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	TotalPrice *int64          `json:"total_price,omitempty"`
+	Totals     []CurrencyTotal `json:"totals"`
+	Services   []ServiceTotal  `json:"services"`
+	Months     []MonthlyBucket `json:"months"`
+}
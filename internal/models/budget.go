@@ -0,0 +1,122 @@
+package models
+
+import "time"
+
+// Срок, на который действует лимит бюджета
+type BudgetPeriod string
+
+const (
+	// Лимит на каждый календарный месяц
+	BudgetMonthly BudgetPeriod = "monthly"
+	// Один лимит на весь период [StartDate, EndDate]
+	BudgetPeriodRange BudgetPeriod = "period"
+)
+
+// Лимит расходов пользователя на подписки; без ServiceName — на все сервисы
+type Budget struct {
+	ID          string       `gorm:"type:uuid;primaryKey"`
+	UserID      string       `gorm:"type:uuid;not null;index"`
+	ServiceName string       `gorm:"not null;default:''"`
+	Amount      int64        `gorm:"not null"`
+	Currency    string       `gorm:"size:3;not null;default:RUB"`
+	Period      BudgetPeriod `gorm:"size:16;not null;default:monthly"`
+	// Границы периода (первые числа месяцев); заданы только у бюджетов на период
+	StartDate *time.Time
+	EndDate   *time.Time
+	CreatedAt time.Time
+	// Зафиксированные превышения удаляются вместе с бюджетом
+	Breaches []BudgetBreach `gorm:"foreignKey:BudgetID;constraint:OnDelete:CASCADE"`
+}
+
+type BudgetDTO struct {
+	ID          string       `json:"id"`
+	UserID      string       `json:"user_id"`
+	ServiceName string       `json:"service_name,omitempty"`
+	Limit       int64        `json:"limit"`
+	Currency    string       `json:"currency"`
+	Period      BudgetPeriod `json:"period"`
+	// Границы периода в формате MM-YYYY; только для period
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+}
+
+// Сравнение фактических расходов с лимитом бюджета за период [From, To]
+type BudgetEvaluation struct {
+	BudgetID string `json:"budget_id"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Limit    int64  `json:"limit"`
+	// Расходы с начала периода, но не позже текущего месяца
+	Spent int64 `json:"spent"`
+	// Остаток лимита; отрицательный при превышении
+	Remaining int64  `json:"remaining"`
+	Currency  string `json:"currency"`
+	Exceeded  bool   `json:"exceeded"`
+}
+
+// Превышение бюджета, зафиксированное фоновой проверкой. Для бюджета и
+// начала периода хранится одна запись, Spent обновляется при повторных проверках
+type BudgetBreach struct {
+	ID          uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	BudgetID    string    `json:"budget_id" gorm:"type:uuid;not null;uniqueIndex:idx_budget_breaches_period"`
+	PeriodStart time.Time `json:"period_start" gorm:"not null;uniqueIndex:idx_budget_breaches_period"`
+	PeriodEnd   time.Time `json:"period_end" gorm:"not null"`
+	Limit       int64     `json:"limit" gorm:"column:limit_amount;not null"`
+	Spent       int64     `json:"spent" gorm:"not null"`
+	Currency    string    `json:"currency" gorm:"size:3;not null"`
+	DetectedAt  time.Time `json:"detected_at" gorm:"not null"`
+}
+
+// Конвертация DTO → модель
+func ToBudget(dto BudgetDTO) (*Budget, error) {
+	var errs ValidationErrors
+
+	budget := &Budget{
+		ID:          dto.ID,
+		UserID:      dto.UserID,
+		ServiceName: dto.ServiceName,
+		Amount:      dto.Limit,
+		Currency:    dto.Currency,
+		Period:      dto.Period,
+	}
+	if dto.StartDate != nil {
+		t, err := time.Parse(monthLayout, *dto.StartDate)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "start_date", Message: "должен быть MM-YYYY"})
+		}
+		budget.StartDate = &t
+	}
+	if dto.EndDate != nil {
+		t, err := time.Parse(monthLayout, *dto.EndDate)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "end_date", Message: "должен быть MM-YYYY"})
+		}
+		budget.EndDate = &t
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return budget, nil
+}
+
+// Конвертация модель → DTO
+func ToBudgetDTO(budget Budget) BudgetDTO {
+	dto := BudgetDTO{
+		ID:          budget.ID,
+		UserID:      budget.UserID,
+		ServiceName: budget.ServiceName,
+		Limit:       budget.Amount,
+		Currency:    budget.Currency,
+		Period:      budget.Period,
+	}
+	if budget.StartDate != nil {
+		startStr := budget.StartDate.Format(monthLayout)
+		dto.StartDate = &startStr
+	}
+	if budget.EndDate != nil {
+		endStr := budget.EndDate.Format(monthLayout)
+		dto.EndDate = &endStr
+	}
+	return dto
+}
@@ -0,0 +1,70 @@
+package models
+
+// Категория сервисов, не найденных в справочнике
+const UncategorizedCategory = "uncategorized"
+
+// Сервис из справочника: каноническое название, под которым хранятся подписки,
+// его псевдонимы, категория и цена по умолчанию для новых подписок
+type CatalogService struct {
+	ID       string `gorm:"type:uuid;primaryKey"`
+	Name     string `gorm:"not null;uniqueIndex"`
+	Category string `gorm:"not null;index"`
+	// Цена, подставляемая в подписку без указанной цены
+	DefaultPrice *int
+	Currency     string         `gorm:"size:3;not null;default:RUB"`
+	Aliases      []CatalogAlias `gorm:"foreignKey:ServiceID;constraint:OnDelete:CASCADE"`
+}
+
+// Псевдоним сервиса в нормализованном виде: нижний регистр, одиночные пробелы
+type CatalogAlias struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	ServiceID string `gorm:"type:uuid;not null;index"`
+	Alias     string `gorm:"not null;uniqueIndex"`
+}
+
+// Результат приведения названий подписок к каноническим
+type NormalizeResult struct {
+	Renamed int64 `json:"renamed"`
+}
+
+type CatalogServiceDTO struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Другие написания названия, например "netflix" или "Нетфликс"
+	Aliases  []string `json:"aliases"`
+	Category string   `json:"category"`
+	// Цена по умолчанию в валюте currency
+	DefaultPrice *int   `json:"default_price,omitempty"`
+	Currency     string `json:"currency"`
+}
+
+// Конвертация DTO → модель
+func ToCatalogService(dto CatalogServiceDTO) *CatalogService {
+	svc := &CatalogService{
+		ID:           dto.ID,
+		Name:         dto.Name,
+		Category:     dto.Category,
+		DefaultPrice: dto.DefaultPrice,
+		Currency:     dto.Currency,
+	}
+	for _, alias := range dto.Aliases {
+		svc.Aliases = append(svc.Aliases, CatalogAlias{ServiceID: dto.ID, Alias: alias})
+	}
+	return svc
+}
+
+// Конвертация модель → DTO
+func ToCatalogServiceDTO(svc CatalogService) CatalogServiceDTO {
+	dto := CatalogServiceDTO{
+		ID:           svc.ID,
+		Name:         svc.Name,
+		Aliases:      make([]string, 0, len(svc.Aliases)),
+		Category:     svc.Category,
+		DefaultPrice: svc.DefaultPrice,
+		Currency:     svc.Currency,
+	}
+	for _, alias := range svc.Aliases {
+		dto.Aliases = append(dto.Aliases, alias.Alias)
+	}
+	return dto
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// Вид скидки на подписку
+type DiscountKind string
+
+const (
+	// Процент от суммы списания
+	DiscountPercent DiscountKind = "percent"
+	// Фиксированная сумма в валюте подписки с каждого списания
+	DiscountFixed DiscountKind = "fixed"
+)
+
+// Скидка на подписку, действующая в месяцах [ValidFrom, ValidUntil];
+// пустая граница — без ограничения с этой стороны
+type SubscriptionDiscount struct {
+	ID             uint64       `gorm:"primaryKey;autoIncrement"`
+	SubscriptionID string       `gorm:"type:uuid;not null;index"`
+	Kind           DiscountKind `gorm:"size:16;not null"`
+	Value          int          `gorm:"not null"`
+	// Код купона, по которому получена скидка
+	Code       string `gorm:"not null;default:''"`
+	ValidFrom  *time.Time
+	ValidUntil *time.Time
+	CreatedAt  time.Time
+}
+
+type SubscriptionDiscountDTO struct {
+	ID uint64 `json:"id"`
+	// percent или fixed
+	Kind DiscountKind `json:"kind"`
+	// Процент (1–100) или сумма за одно списание
+	Value int    `json:"value"`
+	Code  string `json:"code,omitempty"`
+	// Границы действия в формате MM-YYYY, включительно
+	ValidFrom  *string `json:"valid_from,omitempty"`
+	ValidUntil *string `json:"valid_until,omitempty"`
+}
+
+// Конвертация DTO → модель
+func ToSubscriptionDiscount(dto SubscriptionDiscountDTO) (*SubscriptionDiscount, error) {
+	var errs ValidationErrors
+
+	discount := &SubscriptionDiscount{
+		Kind:  dto.Kind,
+		Value: dto.Value,
+		Code:  dto.Code,
+	}
+	if dto.ValidFrom != nil {
+		t, err := time.Parse(monthLayout, *dto.ValidFrom)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "valid_from", Message: "должен быть MM-YYYY"})
+		}
+		discount.ValidFrom = &t
+	}
+	if dto.ValidUntil != nil {
+		t, err := time.Parse(monthLayout, *dto.ValidUntil)
+		if err != nil {
+			errs = append(errs, FieldError{Field: "valid_until", Message: "должен быть MM-YYYY"})
+		}
+		discount.ValidUntil = &t
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return discount, nil
+}
+
+// Конвертация модель → DTO
+func ToSubscriptionDiscountDTO(discount SubscriptionDiscount) SubscriptionDiscountDTO {
+	dto := SubscriptionDiscountDTO{
+		ID:    discount.ID,
+		Kind:  discount.Kind,
+		Value: discount.Value,
+		Code:  discount.Code,
+	}
+	if discount.ValidFrom != nil {
+		fromStr := discount.ValidFrom.Format(monthLayout)
+		dto.ValidFrom = &fromStr
+	}
+	if discount.ValidUntil != nil {
+		untilStr := discount.ValidUntil.Format(monthLayout)
+		dto.ValidUntil = &untilStr
+	}
+	return dto
+}
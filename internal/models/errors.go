@@ -0,0 +1,37 @@
+package models
+
+import "strings"
+
+// Ошибка значения конкретного поля; Field пустой — ошибка относится к запросу целиком
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// Набор ошибок валидации по полям
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldErr := range e {
+		messages = append(messages, fieldErr.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Ответ об ошибке в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
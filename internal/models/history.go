@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Вид изменения подписки в истории
+type ChangeAction string
+
+const (
+	ChangeCreate         ChangeAction = "create"
+	ChangeUpdate         ChangeAction = "update"
+	ChangeDelete         ChangeAction = "delete"
+	ChangeRestore        ChangeAction = "restore"
+	ChangePurge          ChangeAction = "purge"
+	ChangePause          ChangeAction = "pause"
+	ChangeResume         ChangeAction = "resume"
+	ChangeCancel         ChangeAction = "cancel"
+	ChangeDiscountAdd    ChangeAction = "discount_add"
+	ChangeDiscountRemove ChangeAction = "discount_remove"
+)
+
+// Запись истории изменений подписки. Таблица только пополняется:
+// записи не изменяются и не удаляются, в том числе при очистке подписок
+type SubscriptionChange struct {
+	ID             uint64       `json:"id" gorm:"primaryKey;autoIncrement"`
+	SubscriptionID string       `json:"subscription_id" gorm:"type:uuid;not null;index"`
+	Action         ChangeAction `json:"action" gorm:"size:16;not null"`
+	// Кто выполнил изменение
+	Actor     string    `json:"actor" gorm:"not null"`
+	ChangedAt time.Time `json:"changed_at" gorm:"not null;index"`
+	// Состояние подписки до и после изменения; before пуст при создании, after — при удалении
+	Before json.RawMessage `json:"before,omitempty" gorm:"type:jsonb" swaggertype:"object"`
+	After  json.RawMessage `json:"after,omitempty" gorm:"type:jsonb" swaggertype:"object"`
+}
+
+// Параметры выборки ленты изменений
+type ChangeQuery struct {
+	// Изменения в интервале [From, To); nil — без ограничения
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Offset int
+}
+
+// Страница ленты изменений
+type ChangePage struct {
+	Data   []SubscriptionChange `json:"data"`
+	Total  int64                `json:"total"`
+	Limit  int                  `json:"limit"`
+	Offset int                  `json:"offset"`
+}
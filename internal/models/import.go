@@ -0,0 +1,52 @@
+package models
+
+// Режим массового импорта подписок
+type ImportMode string
+
+const (
+	// Все строки сохраняются в одной транзакции; при любой ошибке не сохраняется ничего
+	ImportAtomic ImportMode = "atomic"
+	// Сохраняются все корректные строки, ошибочные пропускаются
+	ImportBestEffort ImportMode = "best_effort"
+)
+
+// Формат файла импорта
+type ImportFormat string
+
+const (
+	ImportCSV   ImportFormat = "csv"
+	ImportJSONL ImportFormat = "jsonl"
+)
+
+// Строка файла импорта после разбора. Err — ошибка разбора самой строки
+type ImportRecord struct {
+	Line int
+	DTO  SubscriptionDTO
+	Err  error
+}
+
+// Итог импорта одной строки
+type ImportStatus string
+
+const (
+	ImportCreated ImportStatus = "created"
+	ImportFailed  ImportStatus = "failed"
+	// Строка корректна, но не сохранена из-за ошибок в других строках (режим atomic)
+	ImportSkipped ImportStatus = "skipped"
+)
+
+type ImportRowResult struct {
+	Line   int          `json:"line"`
+	Status ImportStatus `json:"status"`
+	ID     string       `json:"id,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// Отчёт о массовом импорте с результатом по каждой строке
+type ImportReport struct {
+	Mode    ImportMode        `json:"mode"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Rows    []ImportRowResult `json:"rows"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Поле сортировки списка подписок
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// Параметры выборки списка подписок
+type ListQuery struct {
+	UserID      string
+	ServiceName string
+	// Подписки, действующие в интервале [ActiveFrom, ActiveTo)
+	ActiveFrom *time.Time
+	ActiveTo   *time.Time
+	MinPrice   *int
+	MaxPrice   *int
+	Sort       []SortField
+	Limit      int
+	Offset     int
+	// Фильтр по тегам
+	Tags TagFilter
+	// Включать мягко удалённые подписки
+	IncludeDeleted bool
+}
+
+// Результат очистки удалённых подписок
+type PurgeResult struct {
+	Purged        int64  `json:"purged"`
+	DeletedBefore string `json:"deleted_before"`
+}
+
+// Страница списка подписок
+type SubscriptionPage struct {
+	Data   []SubscriptionDTO `json:"data"`
+	Total  int64             `json:"total"`
+	Limit  int               `json:"limit"`
+	Offset int               `json:"offset"`
+}
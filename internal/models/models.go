@@ -2,72 +2,203 @@ package models
 
 import (
 	"fmt"
+	"gorm.io/gorm"
 	"time"
 )
 
+// Валюта подписок, созданных без явного указания валюты
+const DefaultCurrency = "RUB"
+
+// Периодичность списания оплаты за подписку
+type BillingPeriod string
+
+const (
+	BillingWeekly    BillingPeriod = "weekly"
+	BillingMonthly   BillingPeriod = "monthly"
+	BillingQuarterly BillingPeriod = "quarterly"
+	BillingYearly    BillingPeriod = "yearly"
+)
+
 type Subscription struct {
-	ID          string     `json:"id" gorm:"type:uuid;primaryKey"`
-	ServiceName string     `json:"service_name"`
-	Price       int        `json:"price"`
-	UserID      string     `json:"user_id"`
-	StartDate   time.Time  `json:"start_date"`
-	EndDate     *time.Time `json:"end_date,omitempty"`
+	ID            string        `json:"id" gorm:"type:uuid;primaryKey"`
+	ServiceName   string        `json:"service_name"`
+	Price         int           `json:"price"`
+	Currency      string        `json:"currency" gorm:"size:3;not null;default:RUB"`
+	BillingPeriod BillingPeriod `json:"billing_period" gorm:"size:16;not null;default:monthly"`
+	UserID        string        `json:"user_id"`
+	StartDate     time.Time     `json:"start_date"`
+	EndDate       *time.Time    `json:"end_date,omitempty"`
+	// Даты заданы с точностью до дня; иначе — до месяца (MM-YYYY)
+	DayPrecision bool `json:"day_precision" gorm:"not null;default:false"`
+	// Состояние подписки; меняется только допустимыми переходами
+	Status SubscriptionStatus `json:"status" gorm:"size:16;not null;default:active"`
+	// Первый оплачиваемый месяц после пробного периода; пусто — пробного периода не было
+	// или он бессрочный и ещё не закончился (status = trial)
+	TrialUntil *time.Time `json:"trial_until,omitempty"`
+	// Длина бесплатного пробного периода в месяцах от месяца start_date
+	TrialMonths int `json:"trial_months" gorm:"not null;default:0"`
+	// Вводная цена на первые IntroMonths оплачиваемых месяцев после пробного периода
+	IntroPrice  *int `json:"intro_price,omitempty"`
+	IntroMonths int  `json:"intro_months" gorm:"not null;default:0"`
+	// Произвольные теги для фильтрации и группировки итогов, например team:backend
+	Tags Tags `json:"tags" gorm:"type:jsonb;not null;default:'{}';index:idx_subscriptions_tags,type:gin"`
+	// Версия записи для оптимистичной блокировки; увеличивается при каждом изменении
+	Version int `json:"version" gorm:"not null;default:1"`
+	// Время мягкого удаления; удалённые записи не попадают в выборки без Unscoped
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	// История цен по возрастанию EffectiveFrom; загружается только для расчёта стоимости
+	Prices []SubscriptionPrice `json:"-" gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
+	// Паузы по возрастанию StartDate; загружаются только для расчёта стоимости
+	Pauses []SubscriptionPause `json:"-" gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
+	// Скидки; загружаются только для расчёта стоимости
+	Discounts []SubscriptionDiscount `json:"-" gorm:"foreignKey:SubscriptionID;constraint:OnDelete:CASCADE"`
 }
 
 type SubscriptionDTO struct {
-	ID          string  `json:"id"`
-	ServiceName string  `json:"service_name"`
-	Price       int     `json:"price"`
-	UserID      string  `json:"user_id"`
-	StartDate   string  `json:"start_date"`
-	EndDate     *string `json:"end_date,omitempty"`
+	ID            string        `json:"id"`
+	ServiceName   string        `json:"service_name"`
+	Price         int           `json:"price"`
+	Currency      string        `json:"currency"`
+	BillingPeriod BillingPeriod `json:"billing_period"`
+	UserID        string        `json:"user_id"`
+	StartDate     string        `json:"start_date"`
+	EndDate       *string       `json:"end_date,omitempty"`
+	// Статус; при создании — trial или active (по умолчанию), дальше меняется
+	// только допустимыми переходами
+	Status SubscriptionStatus `json:"status"`
+	// Первый оплачиваемый месяц после пробного периода (MM-YYYY), для дат
+	// с точностью до дня — первый оплачиваемый день (YYYY-MM-DD); только для чтения
+	TrialUntil *string `json:"trial_until,omitempty"`
+	// Бесплатный пробный период в месяцах; подписка создаётся в статусе trial
+	TrialMonths int `json:"trial_months,omitempty"`
+	// Вводная цена и число месяцев, на которые она действует после пробного периода
+	IntroPrice  *int `json:"intro_price,omitempty"`
+	IntroMonths int  `json:"intro_months,omitempty"`
+	// Теги вида "key:value" или "key", например ["team:backend", "cost-center:42"];
+	// у одного ключа может быть только одно значение
+	Tags []string `json:"tags,omitempty"`
+	// Версия записи; отдаётся клиенту в заголовке ETag
+	Version int `json:"-"`
+	// Время удаления (RFC 3339); только для чтения, заполнено у удалённых подписок
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	// Месяц (MM-YYYY), с которого действует новая цена при её изменении;
+	// по умолчанию — текущий месяц. Только для записи
+	PriceEffectiveFrom *string `json:"price_effective_from,omitempty"`
+}
+
+const (
+	monthLayout = "01-2006"
+	dayLayout   = "2006-01-02"
+)
+
+// Разбор даты в формате MM-YYYY или YYYY-MM-DD; второе значение — указан ли день
+func ParseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(monthLayout, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(dayLayout, value)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, true, nil
 }
 
 // Конвертация DTO → модель
 func ToSubscription(dto SubscriptionDTO) (*Subscription, error) {
-	const monthLayout = "01-2006"
+	var errs ValidationErrors
 
-	start, err := time.Parse(monthLayout, dto.StartDate)
+	start, startDay, err := ParseDate(dto.StartDate)
 	if err != nil {
-		return nil, fmt.Errorf("start_date должен быть MM-YYYY")
+		errs = append(errs, FieldError{Field: "start_date", Message: "должен быть MM-YYYY или YYYY-MM-DD"})
 	}
 
 	var end *time.Time
+	dayPrecision := startDay
 	if dto.EndDate != nil {
-		t, err := time.Parse(monthLayout, *dto.EndDate)
+		t, endDay, err := ParseDate(*dto.EndDate)
 		if err != nil {
-			return nil, fmt.Errorf("end_date должен быть MM-YYYY")
+			errs = append(errs, FieldError{Field: "end_date", Message: "должен быть MM-YYYY или YYYY-MM-DD"})
+		} else {
+			// Месяц окончания в записи с точностью до дня — последний день этого месяца
+			if startDay && !endDay {
+				t = t.AddDate(0, 1, -1)
+			}
+			dayPrecision = dayPrecision || endDay
+			end = &t
 		}
-		end = &t
-		if end.Before(start) {
-			return nil, fmt.Errorf("end_date не может быть раньше start_date")
+	}
+
+	tags := make(Tags, len(dto.Tags))
+	for _, tag := range dto.Tags {
+		key, value := ParseTag(tag)
+		if prev, ok := tags[key]; ok && prev != value {
+			errs = append(errs, FieldError{Field: "tags", Message: fmt.Sprintf("тег %s указан с разными значениями", key)})
 		}
+		tags[key] = value
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if end != nil && end.Before(start) {
+		return nil, ValidationErrors{{Field: "end_date", Message: "не может быть раньше start_date"}}
 	}
 
 	return &Subscription{
-		ID:          dto.ID,
-		ServiceName: dto.ServiceName,
-		Price:       dto.Price,
-		UserID:      dto.UserID,
-		StartDate:   start,
-		EndDate:     end,
+		ID:            dto.ID,
+		ServiceName:   dto.ServiceName,
+		Price:         dto.Price,
+		Currency:      dto.Currency,
+		BillingPeriod: dto.BillingPeriod,
+		UserID:        dto.UserID,
+		StartDate:     start,
+		EndDate:       end,
+		DayPrecision:  dayPrecision,
+		Status:        dto.Status,
+		TrialMonths:   dto.TrialMonths,
+		IntroPrice:    dto.IntroPrice,
+		IntroMonths:   dto.IntroMonths,
+		Tags:          tags,
 	}, nil
 }
 
 // Конвертация модель → DTO
 func ToSubscriptionDTO(sub Subscription) SubscriptionDTO {
-	const monthLayout = "01-2006"
+	layout := monthLayout
+	if sub.DayPrecision {
+		layout = dayLayout
+	}
 
 	dto := SubscriptionDTO{
-		ID:          sub.ID,
-		ServiceName: sub.ServiceName,
-		Price:       sub.Price,
-		UserID:      sub.UserID,
-		StartDate:   sub.StartDate.Format(monthLayout),
+		ID:            sub.ID,
+		ServiceName:   sub.ServiceName,
+		Price:         sub.Price,
+		Currency:      sub.Currency,
+		BillingPeriod: sub.BillingPeriod,
+		UserID:        sub.UserID,
+		StartDate:     sub.StartDate.Format(layout),
+		Status:        sub.Status,
+		TrialMonths:   sub.TrialMonths,
+		IntroPrice:    sub.IntroPrice,
+		IntroMonths:   sub.IntroMonths,
+		Tags:          sub.Tags.List(),
+		Version:       sub.Version,
 	}
 	if sub.EndDate != nil {
-		endStr := sub.EndDate.Format(monthLayout)
+		endStr := sub.EndDate.Format(layout)
 		dto.EndDate = &endStr
 	}
+	if sub.TrialUntil != nil {
+		trialStr := sub.TrialUntil.Format(layout)
+		dto.TrialUntil = &trialStr
+		// Пробный период, срок которого истёк, — уже действующая подписка
+		if sub.Status == StatusTrial && !time.Now().Before(*sub.TrialUntil) {
+			dto.Status = StatusActive
+		}
+	}
+	if sub.DeletedAt.Valid {
+		deletedStr := sub.DeletedAt.Time.UTC().Format(time.RFC3339)
+		dto.DeletedAt = &deletedStr
+	}
 	return dto
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Запись истории цены: цена действует с месяца EffectiveFrom до следующей записи.
+// История появляется при первом изменении цены; без неё весь срок действует Subscription.Price
+type SubscriptionPrice struct {
+	ID             uint64    `gorm:"primaryKey;autoIncrement"`
+	SubscriptionID string    `gorm:"type:uuid;not null;uniqueIndex:idx_subscription_prices_effective"`
+	EffectiveFrom  time.Time `gorm:"not null;uniqueIndex:idx_subscription_prices_effective"`
+	Price          int       `gorm:"not null"`
+}
+
+// Цена подписки, действующая с месяца EffectiveFrom (MM-YYYY)
+type PricePoint struct {
+	EffectiveFrom string `json:"effective_from"`
+	Price         int    `json:"price"`
+}
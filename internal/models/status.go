@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Состояние подписки
+type SubscriptionStatus string
+
+const (
+	// Бесплатный пробный период: месяцы до TrialUntil не оплачиваются
+	StatusTrial     SubscriptionStatus = "trial"
+	StatusActive    SubscriptionStatus = "active"
+	StatusPaused    SubscriptionStatus = "paused"
+	StatusCancelled SubscriptionStatus = "cancelled"
+)
+
+// Пауза подписки: месяцы [StartDate, EndDate) не оплачиваются.
+// EndDate пуст, пока подписка не возобновлена
+type SubscriptionPause struct {
+	ID             uint64    `gorm:"primaryKey;autoIncrement"`
+	SubscriptionID string    `gorm:"type:uuid;not null;index"`
+	StartDate      time.Time `gorm:"not null"`
+	EndDate        *time.Time
+}
+
+// Параметры паузы, возобновления и отмены подписки
+type StatusChangeDTO struct {
+	// Месяц (MM-YYYY), с которого действует пауза или возобновление; по умолчанию текущий
+	From *string `json:"from,omitempty"`
+	// Дата окончания при отмене (MM-YYYY или YYYY-MM-DD); по умолчанию текущий месяц
+	EndDate *string `json:"end_date,omitempty"`
+}
+
+// Подписка, у которой заканчивается пробный период: месяц первой оплаты
+// (MM-YYYY, для дат с точностью до дня — YYYY-MM-DD) и первое платное списание (YYYY-MM-DD)
+type TrialEnding struct {
+	ID                string `json:"id"`
+	ServiceName       string `json:"service_name"`
+	UserID            string `json:"user_id"`
+	TrialUntil        string `json:"trial_until"`
+	FirstChargeDate   string `json:"first_charge_date"`
+	FirstChargeAmount int64  `json:"first_charge_amount"`
+	Currency          string `json:"currency"`
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Теги подписки: ключ → значение, например team → backend для тега "team:backend".
+// Тег без значения ("vip") хранится с пустым значением
+type Tags map[string]string
+
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]string(t))
+	return string(data), err
+}
+
+func (t *Tags) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("неподдерживаемый тип значения тегов: %T", src)
+	}
+	return json.Unmarshal(data, (*map[string]string)(t))
+}
+
+// Тег с ключом key в виде "key:value" или "key"; пусто, если тега нет
+func (t Tags) Label(key string) string {
+	value, ok := t[key]
+	switch {
+	case !ok:
+		return ""
+	case value == "":
+		return key
+	}
+	return key + ":" + value
+}
+
+// Теги в виде "key:value" по алфавиту ключей
+func (t Tags) List() []string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := make([]string, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, t.Label(key))
+	}
+	return list
+}
+
+func (t Tags) Equal(other Tags) bool {
+	if len(t) != len(other) {
+		return false
+	}
+	for key, value := range t {
+		if otherValue, ok := other[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Разбор тега "key:value" или "key"; ключ приводится к нижнему регистру
+func ParseTag(tag string) (string, string) {
+	key, value, _ := strings.Cut(tag, ":")
+	return strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+}
+
+// Условие фильтра по тегу: есть тег с ключом Key (и значением Value, если оно задано);
+// Negate — такого тега нет
+type TagCondition struct {
+	Key    string
+	Value  string
+	Negate bool
+}
+
+// Выражение фильтра по тегам: условия внутри группы объединяются по ИЛИ,
+// группы — по И. Пустой фильтр пропускает все подписки
+type TagFilter [][]TagCondition
+
+// Измерение группировки по значению тега: "tag:team"
+const tagDimensionPrefix = "tag:"
+
+func GroupByTag(key string) GroupDimension {
+	return GroupDimension(tagDimensionPrefix + key)
+}
+
+// Ключ тега, если измерение — группировка по тегу
+func (d GroupDimension) TagKey() (string, bool) {
+	return strings.CutPrefix(string(d), tagDimensionPrefix)
+}
+
+// Ключ тега, по которому группируются итоги, если такое измерение есть
+func TagGroupKey(groupBy []GroupDimension) (string, bool) {
+	for _, dim := range groupBy {
+		if key, ok := dim.TagKey(); ok {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+func (r *repository) CreateBudget(budget *models.Budget) error {
+	return translateError(r.db.Create(budget).Error)
+}
+
+// Бюджеты пользователя; без userID — все бюджеты
+func (r *repository) GetBudgets(userID string) ([]models.Budget, error) {
+	db := r.db.Order("created_at").Order("id")
+	if userID != "" {
+		db = db.Where("user_id = ?", userID)
+	}
+
+	budgets := []models.Budget{}
+	err := db.Find(&budgets).Error
+	return budgets, err
+}
+
+func (r *repository) GetBudgetByID(id string) (*models.Budget, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	var budget models.Budget
+	if err := r.db.First(&budget, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &budget, nil
+}
+
+func (r *repository) DeleteBudgetByID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	result := r.db.Delete(&models.Budget{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Фиксирует превышение; для уже известного превышения за тот же период
+// обновляются только потраченная сумма и лимит
+func (r *repository) SaveBudgetBreach(breach *models.BudgetBreach) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "budget_id"}, {Name: "period_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"period_end", "limit_amount", "spent", "currency"}),
+	}).Create(breach).Error
+	return translateError(err)
+}
+
+// Превышения бюджета от новых периодов к старым
+func (r *repository) GetBudgetBreaches(budgetID string) ([]models.BudgetBreach, error) {
+	breaches := []models.BudgetBreach{}
+	err := r.db.Where("budget_id = ?", budgetID).Order("period_start DESC").Find(&breaches).Error
+	return breaches, err
+}
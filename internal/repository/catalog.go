@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Справочник сервисов по названию
+func (r *repository) GetCatalog() ([]models.CatalogService, error) {
+	services := []models.CatalogService{}
+	err := r.db.Scopes(preloadAliases).Order("name").Find(&services).Error
+	return services, err
+}
+
+func (r *repository) GetCatalogServiceByID(id string) (*models.CatalogService, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	var svc models.CatalogService
+	if err := r.db.Scopes(preloadAliases).First(&svc, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &svc, nil
+}
+
+// Создание записи справочника вместе с псевдонимами
+func (r *repository) CreateCatalogService(svc *models.CatalogService) error {
+	return translateError(r.db.Create(svc).Error)
+}
+
+// Обновление записи справочника; псевдонимы заменяются целиком
+func (r *repository) UpdateCatalogService(svc *models.CatalogService) error {
+	if _, err := uuid.Parse(svc.ID); err != nil {
+		return ErrNotFound
+	}
+
+	result := r.db.Model(&models.CatalogService{}).Where("id = ?", svc.ID).Updates(map[string]interface{}{
+		"name":          svc.Name,
+		"category":      svc.Category,
+		"default_price": svc.DefaultPrice,
+		"currency":      svc.Currency,
+	})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if err := r.db.Where("service_id = ?", svc.ID).Delete(&models.CatalogAlias{}).Error; err != nil {
+		return err
+	}
+	if len(svc.Aliases) == 0 {
+		return nil
+	}
+	return translateError(r.db.Create(&svc.Aliases).Error)
+}
+
+func (r *repository) DeleteCatalogServiceByID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	result := r.db.Delete(&models.CatalogService{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Подписки, название сервиса которых в нормализованном виде (нижний регистр,
+// одиночные пробелы) входит в keys, с блокировкой строк до конца транзакции
+func (r *repository) LockSubscriptionsByServiceNames(keys []string) ([]*models.Subscription, error) {
+	var subs []*models.Subscription
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(`lower(regexp_replace(btrim(service_name), '\s+', ' ', 'g')) IN ?`, keys).
+		Order("id").
+		Find(&subs).Error
+	return subs, err
+}
+
+// Фильтр по названию сервиса. Название или псевдоним из справочника
+// заменяется каноническим названием, под которым хранятся подписки;
+// название вне справочника сравнивается как есть
+func serviceNameScope(name string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if name == "" {
+			return db
+		}
+		key := utils.CatalogKey(name)
+		return db.Where(`service_name = COALESCE((
+			SELECT name FROM catalog_services WHERE lower(name) = @key
+			UNION ALL
+			SELECT catalog_services.name FROM catalog_aliases
+			JOIN catalog_services ON catalog_services.id = catalog_aliases.service_id
+			WHERE catalog_aliases.alias = @key
+			LIMIT 1), @name)`, map[string]interface{}{"key": key, "name": name})
+	}
+}
+
+func preloadAliases(db *gorm.DB) *gorm.DB {
+	return db.Preload("Aliases", func(db *gorm.DB) *gorm.DB {
+		return db.Order("alias")
+	})
+}
@@ -0,0 +1,25 @@
+package repository
+
+import "aggregationSubscriptions/internal/models"
+
+// Скидки подписки в порядке добавления
+func (r *repository) GetSubscriptionDiscounts(id string) ([]models.SubscriptionDiscount, error) {
+	discounts := []models.SubscriptionDiscount{}
+	err := r.db.Where("subscription_id = ?", id).Order("id").Find(&discounts).Error
+	return discounts, err
+}
+
+func (r *repository) CreateSubscriptionDiscount(discount *models.SubscriptionDiscount) error {
+	return translateError(r.db.Create(discount).Error)
+}
+
+func (r *repository) DeleteSubscriptionDiscount(subscriptionID string, discountID uint64) error {
+	result := r.db.Where("subscription_id = ?", subscriptionID).Delete(&models.SubscriptionDiscount{}, discountID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func (r *repository) CreateChange(change *models.SubscriptionChange) error {
+	return translateError(r.db.Create(change).Error)
+}
+
+// История одной подписки от старых изменений к новым
+func (r *repository) GetSubscriptionHistory(id string) ([]models.SubscriptionChange, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	changes := []models.SubscriptionChange{}
+	err := r.db.Where("subscription_id = ?", id).Order("changed_at").Order("id").Find(&changes).Error
+	return changes, err
+}
+
+// Лента изменений всех подписок за интервал времени
+func (r *repository) GetChanges(query models.ChangeQuery) ([]models.SubscriptionChange, int64, error) {
+	db := r.db.Model(&models.SubscriptionChange{})
+	if query.From != nil {
+		db = db.Where("changed_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("changed_at < ?", *query.To)
+	}
+
+	// Новая сессия, чтобы подсчёт и выборка не делили одно состояние запроса
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	changes := []models.SubscriptionChange{}
+	err := db.Order("changed_at").Order("id").Limit(query.Limit).Offset(query.Offset).Find(&changes).Error
+	return changes, total, err
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"time"
+)
+
+func (r *repository) CreateSubscriptionPause(pause *models.SubscriptionPause) error {
+	return translateError(r.db.Create(pause).Error)
+}
+
+// Завершает открытую паузу подписки с месяца end. ErrNotFound — открытой паузы
+// нет или она начинается позже end
+func (r *repository) EndSubscriptionPause(subscriptionID string, end time.Time) error {
+	result := r.db.Model(&models.SubscriptionPause{}).
+		Where("subscription_id = ? AND end_date IS NULL AND start_date <= ?", subscriptionID, end).
+		Update("end_date", end)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
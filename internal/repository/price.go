@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// История цен подписки по возрастанию даты начала действия
+func (r *repository) GetSubscriptionPrices(id string) ([]models.SubscriptionPrice, error) {
+	var prices []models.SubscriptionPrice
+	err := r.db.Where("subscription_id = ?", id).Order("effective_from").Find(&prices).Error
+	return prices, translateError(err)
+}
+
+// Удаляет записи истории цен, вступающие в силу позже after
+func (r *repository) DeleteSubscriptionPricesAfter(id string, after time.Time) error {
+	return r.db.Where("subscription_id = ? AND effective_from > ?", id, after).Delete(&models.SubscriptionPrice{}).Error
+}
+
+// Добавляет запись истории цен; запись с той же датой начала действия заменяется
+func (r *repository) SaveSubscriptionPrice(price *models.SubscriptionPrice) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subscription_id"}, {Name: "effective_from"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price"}),
+	}).Create(price).Error
+	return translateError(err)
+}
@@ -2,20 +2,93 @@ package repository
 
 import (
 	"aggregationSubscriptions/internal/models"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strings"
 	"time"
 )
 
+var (
+	ErrNotFound  = errors.New("запись не найдена")
+	ErrDuplicate = errors.New("запись уже существует")
+	// Агрегация на стороне БД поддерживается только для PostgreSQL
+	ErrAggregationUnsupported = errors.New("агрегация в БД не поддерживается для данного драйвера")
+	// Запись была изменена после того, как клиент получил ожидаемую версию
+	ErrVersionMismatch = errors.New("версия записи не совпадает")
+)
+
+// Версия, при которой условные изменения выполняются без проверки (If-Match: *)
+const AnyVersion = 0
+
 type Repository interface {
-	GetAllSubscriptions() ([]*models.Subscription, error)
+	// Выполняет fn в транзакции; репозиторий, переданный в fn, работает внутри неё
+	Transaction(fn func(repo Repository) error) error
+	GetAllSubscriptions(query models.ListQuery) ([]*models.Subscription, int64, error)
+	StreamSubscriptions(query models.ListQuery, fn func(sub *models.Subscription) error) error
 	GetSubscriptionByID(id string) (*models.Subscription, error)
+	LockSubscriptionByID(id string, includeDeleted bool) (*models.Subscription, error)
 	CreateNewSubscription(sub *models.Subscription) error
-	UpdateSubscriptionByID(id string, data *models.Subscription) (*models.Subscription, error)
-	DeleteSubscriptionByID(id string) error
-	GetCountSubscriptionsPrice(userID string, serviceName string, start time.Time, end time.Time) ([]*models.Subscription, error)
+	UpdateSubscriptionByID(id string, version int, data *models.Subscription) (*models.Subscription, error)
+	PatchSubscriptionByID(id string, version int, changes map[string]interface{}) (*models.Subscription, error)
+	DeleteSubscriptionByID(id string, version int) error
+	RestoreSubscriptionByID(id string, version int) (*models.Subscription, error)
+	PurgeDeletedSubscriptions(before time.Time) ([]*models.Subscription, error)
+	GetCountSubscriptionsPrice(filter PeriodFilter) ([]*models.Subscription, error)
+	GetActiveSubscriptions(userID string, at time.Time) ([]*models.Subscription, error)
+	GetTrialSubscriptions(userID string, from, to time.Time) ([]*models.Subscription, error)
+	SumSubscriptionsPrice(filter PeriodFilter) ([]models.AggregateGroup, error)
+	GetSubscriptionPrices(id string) ([]models.SubscriptionPrice, error)
+	SaveSubscriptionPrice(price *models.SubscriptionPrice) error
+	DeleteSubscriptionPricesAfter(id string, after time.Time) error
+	CreateSubscriptionPause(pause *models.SubscriptionPause) error
+	GetSubscriptionDiscounts(id string) ([]models.SubscriptionDiscount, error)
+	CreateSubscriptionDiscount(discount *models.SubscriptionDiscount) error
+	DeleteSubscriptionDiscount(subscriptionID string, discountID uint64) error
+	EndSubscriptionPause(subscriptionID string, end time.Time) error
+	CreateChange(change *models.SubscriptionChange) error
+	GetSubscriptionHistory(id string) ([]models.SubscriptionChange, error)
+	GetChanges(query models.ChangeQuery) ([]models.SubscriptionChange, int64, error)
+	CreateBudget(budget *models.Budget) error
+	GetBudgets(userID string) ([]models.Budget, error)
+	GetBudgetByID(id string) (*models.Budget, error)
+	DeleteBudgetByID(id string) error
+	SaveBudgetBreach(breach *models.BudgetBreach) error
+	GetBudgetBreaches(budgetID string) ([]models.BudgetBreach, error)
+	GetCatalog() ([]models.CatalogService, error)
+	GetCatalogServiceByID(id string) (*models.CatalogService, error)
+	CreateCatalogService(svc *models.CatalogService) error
+	UpdateCatalogService(svc *models.CatalogService) error
+	DeleteCatalogServiceByID(id string) error
+	LockSubscriptionsByServiceNames(keys []string) ([]*models.Subscription, error)
+}
+
+// Фильтр подписок, активных хотя бы в одном месяце периода [Start, End]
+type PeriodFilter struct {
+	UserID      string
+	ServiceName string
+	Start       time.Time
+	End         time.Time
+	GroupBy     []models.GroupDimension
+	Tags        models.TagFilter
+	// Пропустить подписки, итоги по которым уже посчитал SumSubscriptionsPrice
+	SkipSummable bool
+	// Учитывать мягко удалённые подписки
+	IncludeDeleted bool
 }
 
+// Подписки, стоимость которых можно посчитать в SQL:
+// ежемесячное списание без пропорционального расчёта по дням,
+// одна цена на каждый месяц пересечения с периодом (без истории и вводной цены,
+// без скидок), все месяцы оплачиваются (без пробного периода и пауз)
+const summableCondition = "billing_period = 'monthly' AND NOT day_precision" +
+	" AND status IN ('active', 'cancelled') AND trial_until IS NULL AND intro_price IS NULL" +
+	" AND NOT EXISTS (SELECT 1 FROM subscription_prices WHERE subscription_prices.subscription_id = subscriptions.id)" +
+	" AND NOT EXISTS (SELECT 1 FROM subscription_pauses WHERE subscription_pauses.subscription_id = subscriptions.id)" +
+	" AND NOT EXISTS (SELECT 1 FROM subscription_discounts WHERE subscription_discounts.subscription_id = subscriptions.id)"
+
 type repository struct {
 	db *gorm.DB
 }
@@ -24,63 +97,395 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) GetAllSubscriptions() ([]*models.Subscription, error) {
+func (r *repository) Transaction(fn func(repo Repository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&repository{db: tx})
+	})
+}
+
+func (r *repository) GetAllSubscriptions(query models.ListQuery) ([]*models.Subscription, int64, error) {
 	var subs []*models.Subscription
 
-	err := r.db.Find(&subs).Error
-	return subs, err
+	// Новая сессия, чтобы подсчёт и выборка не делили одно состояние запроса
+	db := r.listQuery(query).Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := orderList(db, query.Sort).Limit(query.Limit).Offset(query.Offset).Find(&subs).Error
+	return subs, total, err
+}
+
+// Построчная выборка подписок без загрузки всего результата в память.
+// Limit 0 — все подходящие записи
+func (r *repository) StreamSubscriptions(query models.ListQuery, fn func(sub *models.Subscription) error) error {
+	db := orderList(r.listQuery(query), query.Sort).Offset(query.Offset)
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sub models.Subscription
+		if err := db.ScanRows(rows, &sub); err != nil {
+			return err
+		}
+		if err := fn(&sub); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Фильтры списка подписок
+func (r *repository) listQuery(query models.ListQuery) *gorm.DB {
+	db := r.db.Model(&models.Subscription{})
+	if query.IncludeDeleted {
+		db = db.Unscoped()
+	}
+
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.ActiveFrom != nil && query.ActiveTo != nil {
+		// end_date помесячной записи — первое число последнего месяца действия
+		db = db.Where("start_date < ? AND (end_date IS NULL OR end_date >= CASE WHEN day_precision THEN ? ELSE ? END)",
+			*query.ActiveTo, *query.ActiveFrom, monthStart(*query.ActiveFrom))
+	}
+	if query.MinPrice != nil {
+		db = db.Where("price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		db = db.Where("price <= ?", *query.MaxPrice)
+	}
+	return db.Scopes(serviceNameScope(query.ServiceName), tagFilterScope(query.Tags))
+}
+
+// Сортировка списка; id в конце делает порядок страниц стабильным
+func orderList(db *gorm.DB, sort []models.SortField) *gorm.DB {
+	for _, field := range sort {
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: field.Column}, Desc: field.Desc})
+	}
+	return db.Order("id")
 }
 
 func (r *repository) GetSubscriptionByID(id string) (*models.Subscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
 	var sub models.Subscription
-	err := r.db.First(&sub, "id = ?", id).Error
-	return &sub, err
+	if err := r.db.First(&sub, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &sub, nil
+}
+
+// Чтение подписки с блокировкой строки до конца транзакции (SELECT ... FOR UPDATE)
+func (r *repository) LockSubscriptionByID(id string, includeDeleted bool) (*models.Subscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	db := r.db
+	if includeDeleted {
+		db = db.Unscoped()
+	}
+
+	var sub models.Subscription
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, "id = ?", id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &sub, nil
 }
 
 func (r *repository) CreateNewSubscription(sub *models.Subscription) error {
+	sub.Version = 1
 	err := r.db.Create(sub).Error
-	return err
+	return translateError(err)
+}
+
+func (r *repository) UpdateSubscriptionByID(id string, version int, data *models.Subscription) (*models.Subscription, error) {
+	return r.updateVersioned(id, version, map[string]interface{}{
+		"service_name":   data.ServiceName,
+		"price":          data.Price,
+		"currency":       data.Currency,
+		"billing_period": data.BillingPeriod,
+		"user_id":        data.UserID,
+		"start_date":     data.StartDate,
+		"end_date":       data.EndDate,
+		"day_precision":  data.DayPrecision,
+		"status":         data.Status,
+		"trial_until":    data.TrialUntil,
+		"trial_months":   data.TrialMonths,
+		"intro_price":    data.IntroPrice,
+		"intro_months":   data.IntroMonths,
+		"tags":           data.Tags,
+	})
+}
+
+// Обновляет только переданные колонки, не затрагивая остальные
+func (r *repository) PatchSubscriptionByID(id string, version int, changes map[string]interface{}) (*models.Subscription, error) {
+	return r.updateVersioned(id, version, changes)
+}
+
+// Мягкое удаление: запись помечается deleted_at и перестаёт попадать в выборки
+func (r *repository) DeleteSubscriptionByID(id string, version int) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrNotFound
+	}
+
+	result := versionScope(r.db.Model(&models.Subscription{}).Where("id = ?", id), version).Updates(map[string]interface{}{
+		"deleted_at": time.Now().UTC(),
+		"version":    gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return r.versionError(id)
+	}
+	return nil
+}
+
+// Снимает пометку удаления с записи версии version. Для действующей записи ничего не меняет
+func (r *repository) RestoreSubscriptionByID(id string, version int) (*models.Subscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	err := versionScope(r.db.Unscoped().Model(&models.Subscription{}).Where("id = ? AND deleted_at IS NOT NULL", id), version).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return r.GetSubscriptionByID(id)
+}
+
+// Окончательно удаляет записи, помеченные удалёнными раньше before,
+// и возвращает их последнее состояние
+func (r *repository) PurgeDeletedSubscriptions(before time.Time) ([]*models.Subscription, error) {
+	var purged []*models.Subscription
+	err := r.db.Unscoped().Clauses(clause.Returning{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Delete(&purged).Error
+	return purged, translateError(err)
 }
 
-func (r *repository) UpdateSubscriptionByID(id string, data *models.Subscription) (*models.Subscription, error) {
-	var subscription models.Subscription
-	if err := r.db.First(&subscription, "id = ?", id).Error; err != nil {
+// Условное обновление: запись меняется, только если её версия совпадает
+// с ожидаемой; версия при этом увеличивается
+func (r *repository) updateVersioned(id string, version int, changes map[string]interface{}) (*models.Subscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+
+	values := make(map[string]interface{}, len(changes)+1)
+	for column, value := range changes {
+		values[column] = value
+	}
+	values["version"] = gorm.Expr("version + 1")
+
+	result := versionScope(r.db.Model(&models.Subscription{}).Where("id = ?", id), version).Updates(values)
+	if result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, r.versionError(id)
+	}
+	return r.GetSubscriptionByID(id)
+}
+
+// Причина, по которой условное изменение не затронуло ни одной строки:
+// записи нет или её версия уже другая
+func (r *repository) versionError(id string) error {
+	if _, err := r.GetSubscriptionByID(id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+// Условие на версию записи; AnyVersion снимает проверку
+func versionScope(db *gorm.DB, version int) *gorm.DB {
+	if version == AnyVersion {
+		return db
+	}
+	return db.Where("version = ?", version)
+}
+
+func (r *repository) GetCountSubscriptionsPrice(filter PeriodFilter) ([]*models.Subscription, error) {
+	var subs []*models.Subscription
+	query, err := r.periodQuery(filter)
+	if err != nil {
 		return nil, err
 	}
-	subscription.ServiceName = data.ServiceName
-	subscription.Price = data.Price
-	subscription.UserID = data.UserID
-	subscription.StartDate = data.StartDate
-	subscription.EndDate = data.EndDate
 
-	if err := r.db.Save(&subscription).Error; err != nil {
+	if filter.SkipSummable {
+		query = query.Not(summableCondition)
+	}
+
+	// Сортируем по измерениям группировки, чтобы порядок групп был стабильным
+	for _, column := range groupColumns(filter.GroupBy) {
+		query = query.Order(column)
+	}
+
+	query = preloadBilling(query)
+
+	if err := query.Find(&subs).Error; err != nil {
 		return nil, err
 	}
-	return &subscription, nil
+	return subs, nil
 }
 
-func (r *repository) DeleteSubscriptionByID(id string) error {
-	err := r.db.Where("id = ?", id).Delete(&models.Subscription{}).Error
-	return err
-}
+// Подписки пользователя, действующие в момент at или начинающиеся позже
+func (r *repository) GetActiveSubscriptions(userID string, at time.Time) ([]*models.Subscription, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, err
+	}
 
-func (r *repository) GetCountSubscriptionsPrice(userID string, serviceName string, start time.Time, end time.Time) ([]*models.Subscription, error) {
 	var subs []*models.Subscription
-	query := r.db.Model(&models.Subscription{}).Where("start_date <= ? AND (end_date IS NULL OR end_date >= ?)", end, start)
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	err := r.db.Where("user_id = ?", userID).
+		// end_date помесячной записи — первое число последнего месяца действия
+		Where("end_date IS NULL OR end_date >= CASE WHEN day_precision THEN ? ELSE ? END", today, monthStart(at)).
+		Scopes(preloadBilling).
+		Order("start_date").Order("id").
+		Find(&subs).Error
+	return subs, err
+}
 
+// Подписки, пробный период которых заканчивается в месяцах [from, to]:
+// первый оплачиваемый месяц попадает в этот интервал
+func (r *repository) GetTrialSubscriptions(userID string, from, to time.Time) ([]*models.Subscription, error) {
+	db := r.db.Where("trial_until >= ? AND trial_until <= ?", monthStart(from), to).
+		Where("status IN ?", []models.SubscriptionStatus{models.StatusTrial, models.StatusActive})
 	if userID != "" {
 		if _, err := uuid.Parse(userID); err != nil {
 			return nil, err
 		}
-		query = query.Where("user_id = ?", userID)
+		db = db.Where("user_id = ?", userID)
 	}
 
-	if serviceName != "" {
-		query = query.Where("service_name = ?", serviceName)
+	var subs []*models.Subscription
+	err := preloadBilling(db).
+		Order("trial_until").Order("id").
+		Find(&subs).Error
+	return subs, err
+}
+
+func (r *repository) SumSubscriptionsPrice(filter PeriodFilter) ([]models.AggregateGroup, error) {
+	if r.db.Dialector.Name() != "postgres" {
+		return nil, ErrAggregationUnsupported
 	}
 
-	if err := query.Find(&subs).Error; err != nil {
+	query, err := r.periodQuery(filter)
+	if err != nil {
 		return nil, err
 	}
-	return subs, nil
+	query = query.Where(summableCondition)
+
+	// Границы пересечения подписки с периодом, приведённые к UTC,
+	// чтобы номер месяца не зависел от часового пояса сессии
+	const (
+		from = "(GREATEST(start_date, @start) AT TIME ZONE 'UTC')"
+		to   = "(LEAST(COALESCE(end_date, @end), @end) AT TIME ZONE 'UTC')"
+	)
+	months := fmt.Sprintf("GREATEST((EXTRACT(YEAR FROM %[2]s) - EXTRACT(YEAR FROM %[1]s)) * 12"+
+		" + EXTRACT(MONTH FROM %[2]s) - EXTRACT(MONTH FROM %[1]s) + 1, 0)", from, to)
+
+	// Суммы в разных валютах не складываются, поэтому валюта — всегда измерение группировки
+	columns := append(groupColumns(filter.GroupBy), "currency")
+	selects := append(append([]string{}, columns...),
+		fmt.Sprintf("COALESCE(SUM(%s), 0)::bigint AS months", months),
+		fmt.Sprintf("COALESCE(SUM(price * %s), 0)::bigint AS subtotal", months),
+	)
+	args := map[string]interface{}{"start": filter.Start, "end": filter.End}
+	// Значение тега группировки в виде "key:value"; GROUP BY по псевдониму колонки
+	if key, ok := models.TagGroupKey(filter.GroupBy); ok {
+		const tagKey = "CAST(@tag_key AS text)"
+		selects = append(selects, fmt.Sprintf("CASE WHEN tags ->> %[1]s IS NULL THEN ''"+
+			" WHEN tags ->> %[1]s = '' THEN %[1]s ELSE %[1]s || ':' || (tags ->> %[1]s) END AS tag", tagKey))
+		columns = append(columns, "tag")
+		args["tag_key"] = key
+	}
+	query = query.Select(strings.Join(selects, ", "), args)
+
+	for _, column := range columns {
+		query = query.Group(column).Order(column)
+	}
+
+	var groups []models.AggregateGroup
+	if err := query.Scan(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// Подгрузка данных для расчёта стоимости: история цен, чтобы каждый месяц
+// считался по действовавшей в нём цене, паузы, чтобы не учитывать
+// неоплачиваемые месяцы, и скидки
+func preloadBilling(db *gorm.DB) *gorm.DB {
+	return db.Preload("Prices", func(db *gorm.DB) *gorm.DB {
+		return db.Order("effective_from")
+	}).Preload("Pauses", func(db *gorm.DB) *gorm.DB {
+		return db.Order("start_date")
+	}).Preload("Discounts", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+}
+
+// Подписки, подходящие под фильтр периода
+func (r *repository) periodQuery(filter PeriodFilter) (*gorm.DB, error) {
+	// Конец периода — весь месяц End, включая подписки, начавшиеся в его середине
+	periodEnd := filter.End.AddDate(0, 1, 0)
+	db := r.db
+	if filter.IncludeDeleted {
+		db = db.Unscoped()
+	}
+	query := db.Model(&models.Subscription{}).Where("start_date < ? AND (end_date IS NULL OR end_date >= ?)", periodEnd, filter.Start)
+
+	if filter.UserID != "" {
+		if _, err := uuid.Parse(filter.UserID); err != nil {
+			return nil, err
+		}
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	return query.Scopes(serviceNameScope(filter.ServiceName), tagFilterScope(filter.Tags)), nil
+}
+
+// Колонки таблицы, соответствующие измерениям группировки
+func groupColumns(groupBy []models.GroupDimension) []string {
+	var columns []string
+	for _, dim := range groupBy {
+		switch dim {
+		case models.GroupByServiceName:
+			columns = append(columns, "service_name")
+		case models.GroupByUserID:
+			columns = append(columns, "user_id")
+		}
+	}
+	return columns
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Перевод ошибок gorm в ошибки репозитория
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
 }
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// Условие фильтра по тегам: группы объединяются по И, условия внутри группы — по ИЛИ
+func tagFilterScope(filter models.TagFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, group := range filter {
+			conditions := make([]string, 0, len(group))
+			var args []interface{}
+			for _, cond := range group {
+				condition := "tags ->> ?::text IS NOT NULL"
+				args = append(args, cond.Key)
+				if cond.Value != "" {
+					condition = "tags @> jsonb_build_object(?::text, ?::text)"
+					args = append(args, cond.Value)
+				}
+				if cond.Negate {
+					condition = "NOT (" + condition + ")"
+				}
+				conditions = append(conditions, condition)
+			}
+			db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		}
+		return db
+	}
+}
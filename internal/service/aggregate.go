@@ -0,0 +1,452 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (s *service) GetSubscriptionsPrice(query models.AggregateQuery) (*models.AggregateResult, error) {
+	start, end, err := parseAggregateQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := parseTagFilter(query.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := s.newConverter(query.ConvertTo)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog utils.Catalog
+	if hasDimension(query.GroupBy, models.GroupByCategory) {
+		if catalog, err = s.loadCatalog(); err != nil {
+			return nil, err
+		}
+	}
+
+	filter := repository.PeriodFilter{
+		UserID:      query.UserID,
+		ServiceName: query.ServiceName,
+		Start:       start,
+		End:         end,
+		GroupBy:     repositoryGroupBy(query.GroupBy),
+		Tags:        tags,
+
+		IncludeDeleted: query.IncludeDeleted,
+	}
+	agg := newAggregator(query.GroupBy)
+
+	// Без детализации по подпискам то, что возможно, считаем в БД,
+	// остальное (или всё, если драйвер не поддерживает) — в памяти
+	if !query.Details {
+		groups, err := s.repo.SumSubscriptionsPrice(filter)
+		switch {
+		case err == nil:
+			// В БД считаются только подписки без скидок: сумма без скидок та же
+			for _, group := range groups {
+				amount, currency, err := conv.convert(group.Subtotal, group.Currency)
+				if err != nil {
+					return nil, err
+				}
+				group.Currency = currency
+				agg.add(groupKey(group, query.GroupBy, catalog), group.Months, amount, amount)
+			}
+			filter.SkipSummable = true
+		case !errors.Is(err, repository.ErrAggregationUnsupported):
+			return nil, err
+		}
+	}
+
+	subs, err := s.repo.GetCountSubscriptionsPrice(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Считаем итоговую цену и промежуточные итоги по группам.
+	// Учитываются только списания, попадающие в период [start, end]
+	periodEnd := end.AddDate(0, 1, 0)
+	for _, sub := range subs {
+		months := utils.OverlapMonths(sub.StartDate, sub.EndDate, start, end)
+		charges := utils.Charges(sub, start, periodEnd)
+		amount, currency, err := conv.convert(utils.SumCharges(charges), sub.Currency)
+		if err != nil {
+			return nil, err
+		}
+		gross, _, err := conv.convert(utils.SumGross(charges), sub.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		if query.Details {
+			agg.subscriptions = append(agg.subscriptions, models.SubscriptionTotal{
+				ID:          sub.ID,
+				ServiceName: sub.ServiceName,
+				UserID:      sub.UserID,
+				Currency:    currency,
+				Months:      months,
+				Charges:     len(charges),
+				Gross:       gross,
+				Subtotal:    amount,
+			})
+		}
+
+		key := models.AggregateGroup{ServiceName: sub.ServiceName, UserID: sub.UserID, Currency: currency}
+		if tagKey, ok := models.TagGroupKey(query.GroupBy); ok {
+			key.Tag = sub.Tags.Label(tagKey)
+		}
+		agg.add(groupKey(key, query.GroupBy, catalog), months, gross, amount)
+	}
+
+	return agg.result(), nil
+
+}
+
+func (s *service) GetSubscriptionsPriceByMonth(query models.AggregateQuery) ([]models.MonthlyBucket, error) {
+	start, end, err := parseAggregateQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := parseTagFilter(query.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := s.newConverter(query.ConvertTo)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := s.repo.GetCountSubscriptionsPrice(repository.PeriodFilter{
+		UserID:      query.UserID,
+		ServiceName: query.ServiceName,
+		Start:       start,
+		End:         end,
+		GroupBy:     []models.GroupDimension{models.GroupByServiceName},
+		Tags:        tags,
+
+		IncludeDeleted: query.IncludeDeleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return monthlyBuckets(subs, start, end, conv)
+}
+
+// Итоги по каждому календарному месяцу периода [start, end]
+func monthlyBuckets(subs []*models.Subscription, start, end time.Time, conv converter) ([]models.MonthlyBucket, error) {
+	// Одна корзина на каждый календарный месяц периода
+	buckets := make([]models.MonthlyBucket, utils.MonthsBetween(start, end))
+	for i := range buckets {
+		month := start.AddDate(0, i, 0)
+		bucket := &buckets[i]
+		bucket.Month = month.Format(monthLayout)
+		bucket.Services = []models.ServiceTotal{}
+
+		var totals currencyTotals
+		serviceIndex := make(map[models.ServiceTotal]int)
+		for _, sub := range subs {
+			if utils.OverlapMonths(sub.StartDate, sub.EndDate, month, month) == 0 {
+				continue
+			}
+			// Месяц паузы или пробного периода не оплачивается: подписка в нём не активна
+			charges := utils.Charges(sub, month, month.AddDate(0, 1, 0))
+			if len(charges) == 0 {
+				continue
+			}
+			bucket.ActiveSubscriptions++
+
+			amount, currency, err := conv.convert(utils.SumCharges(charges), sub.Currency)
+			if err != nil {
+				return nil, err
+			}
+			totals.add(currency, amount)
+
+			key := models.ServiceTotal{ServiceName: sub.ServiceName, Currency: currency}
+			j, ok := serviceIndex[key]
+			if !ok {
+				j = len(bucket.Services)
+				serviceIndex[key] = j
+				bucket.Services = append(bucket.Services, key)
+			}
+			bucket.Services[j].Total += amount
+		}
+
+		bucket.Totals = totals.list()
+		bucket.TotalPrice = totals.single()
+	}
+
+	return buckets, nil
+}
+
+func (s *service) GetNormalizedMonthlyCosts(userID, serviceName, monthStr string) ([]models.NormalizedCost, error) {
+	// По умолчанию — подписки, активные в текущем месяце
+	month := time.Now().UTC()
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if monthStr != "" {
+		var err error
+		if month, err = time.Parse(monthLayout, monthStr); err != nil {
+			return nil, NewValidationError(models.FieldError{Field: "month", Message: "должен быть MM-YYYY"})
+		}
+	}
+	if err := validateUserID(userID); err != nil {
+		return nil, err
+	}
+
+	subs, err := s.repo.GetCountSubscriptionsPrice(repository.PeriodFilter{
+		UserID:      userID,
+		ServiceName: serviceName,
+		Start:       month,
+		End:         month,
+		GroupBy:     []models.GroupDimension{models.GroupByServiceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make([]models.NormalizedCost, 0, len(subs))
+	for _, sub := range subs {
+		// Месяц пробного периода или паузы ничего не стоит
+		if !utils.Billable(sub, month) {
+			continue
+		}
+		costs = append(costs, models.NormalizedCost{
+			ID:            sub.ID,
+			ServiceName:   sub.ServiceName,
+			UserID:        sub.UserID,
+			Currency:      sub.Currency,
+			BillingPeriod: sub.BillingPeriod,
+			Price:         utils.PriceAt(sub, month),
+			MonthlyCost:   utils.MonthlyCost(utils.PriceAt(sub, month), sub.BillingPeriod),
+		})
+	}
+	return costs, nil
+}
+
+// Ключ группы: заполнены только поля, входящие в группировку, и валюта.
+// Категория определяется по названию сервиса из справочника catalog
+func groupKey(group models.AggregateGroup, groupBy []models.GroupDimension, catalog utils.Catalog) models.AggregateGroup {
+	key := models.AggregateGroup{Currency: group.Currency}
+	for _, dim := range groupBy {
+		switch dim {
+		case models.GroupByServiceName:
+			key.ServiceName = group.ServiceName
+		case models.GroupByUserID:
+			key.UserID = group.UserID
+		case models.GroupByCategory:
+			key.Category = catalog.Category(group.ServiceName)
+		default:
+			if _, ok := dim.TagKey(); ok {
+				key.Tag = group.Tag
+			}
+		}
+	}
+	return key
+}
+
+// Группировка для репозитория: категории в БД нет, поэтому вместо неё
+// группируется по названию сервиса, а итоги сводятся по категориям в памяти
+func repositoryGroupBy(groupBy []models.GroupDimension) []models.GroupDimension {
+	if !hasDimension(groupBy, models.GroupByCategory) {
+		return groupBy
+	}
+
+	dims := make([]models.GroupDimension, 0, len(groupBy))
+	for _, dim := range groupBy {
+		if dim == models.GroupByCategory {
+			dim = models.GroupByServiceName
+		}
+		if !hasDimension(dims, dim) {
+			dims = append(dims, dim)
+		}
+	}
+	return dims
+}
+
+func hasDimension(groupBy []models.GroupDimension, dim models.GroupDimension) bool {
+	for _, d := range groupBy {
+		if d == dim {
+			return true
+		}
+	}
+	return false
+}
+
+// Накопитель итогов агрегации: общие суммы по валютам и промежуточные итоги по группам
+type aggregator struct {
+	groupBy       []models.GroupDimension
+	totals        currencyTotals
+	groups        []models.AggregateGroup
+	groupIndex    map[models.AggregateGroup]int
+	subscriptions []models.SubscriptionTotal
+}
+
+func newAggregator(groupBy []models.GroupDimension) *aggregator {
+	return &aggregator{groupBy: groupBy, groupIndex: make(map[models.AggregateGroup]int)}
+}
+
+// amount — сумма с учётом скидок, gross — без них
+func (a *aggregator) add(key models.AggregateGroup, months int, gross, amount int64) {
+	a.totals.add(key.Currency, amount)
+	if len(a.groupBy) == 0 {
+		return
+	}
+
+	key.Months, key.Gross, key.Subtotal = 0, 0, 0
+	i, ok := a.groupIndex[key]
+	if !ok {
+		i = len(a.groups)
+		a.groupIndex[key] = i
+		a.groups = append(a.groups, key)
+	}
+	a.groups[i].Months += months
+	a.groups[i].Gross += gross
+	a.groups[i].Subtotal += amount
+}
+
+func (a *aggregator) result() *models.AggregateResult {
+	return &models.AggregateResult{
+		TotalPrice:    a.totals.single(),
+		Totals:        a.totals.list(),
+		Groups:        a.groups,
+		Subscriptions: a.subscriptions,
+	}
+}
+
+// Суммы в разрезе валют
+type currencyTotals struct {
+	sums map[string]int64
+}
+
+func (t *currencyTotals) add(currency string, amount int64) {
+	if t.sums == nil {
+		t.sums = make(map[string]int64)
+	}
+	t.sums[currency] += amount
+}
+
+func (t *currencyTotals) list() []models.CurrencyTotal {
+	list := make([]models.CurrencyTotal, 0, len(t.sums))
+	for currency, total := range t.sums {
+		list = append(list, models.CurrencyTotal{Currency: currency, Total: total})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Currency < list[j].Currency })
+	return list
+}
+
+// Общая сумма, если все суммы в одной валюте (или сумм нет), иначе nil
+func (t *currencyTotals) single() *int64 {
+	var total int64
+	switch len(t.sums) {
+	case 0:
+	case 1:
+		for _, sum := range t.sums {
+			total = sum
+		}
+	default:
+		return nil
+	}
+	return &total
+}
+
+// Пересчёт сумм в целевую валюту запроса
+type converter struct {
+	rates  *utils.CurrencyRates
+	target string
+}
+
+func (s *service) newConverter(target string) (converter, error) {
+	target = strings.ToUpper(strings.TrimSpace(target))
+	if target == "" {
+		return converter{}, nil
+	}
+
+	if s.rates == nil {
+		return converter{}, NewValidationError(models.FieldError{Field: "convert_to", Message: "таблица курсов валют не загружена"})
+	}
+	if !s.rates.Supports(target) {
+		return converter{}, NewValidationError(models.FieldError{Field: "convert_to", Message: fmt.Sprintf("нет курса для валюты %s", target)})
+	}
+	return converter{rates: s.rates, target: target}, nil
+}
+
+// Возвращает сумму и её валюту после пересчёта
+func (c converter) convert(amount int64, currency string) (int64, string, error) {
+	if c.target == "" {
+		return amount, currency, nil
+	}
+
+	converted, err := c.rates.Convert(amount, currency, c.target)
+	if errors.Is(err, utils.ErrUnknownCurrency) {
+		// Валюта подписки прошла проверку формата, но курса для неё нет
+		return 0, "", NewValidationError(models.FieldError{Field: "convert_to", Message: fmt.Sprintf("нет курса для валюты подписки %s", currency)})
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return converted, c.target, nil
+}
+
+// Проверка параметров агрегации и разбор границ периода
+func parseAggregateQuery(query models.AggregateQuery) (time.Time, time.Time, error) {
+	if err := validateUserID(query.UserID); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return parsePeriod(query.StartDate, query.EndDate)
+}
+
+// Парсинг границ периода в формате MM-YYYY
+func parsePeriod(startStr, endStr string) (time.Time, time.Time, error) {
+	var errs models.ValidationErrors
+
+	start, err := time.Parse(monthLayout, startStr)
+	if err != nil {
+		errs = append(errs, models.FieldError{Field: "start_date", Message: "должен быть MM-YYYY"})
+	}
+
+	end, err := time.Parse(monthLayout, endStr)
+	if err != nil {
+		errs = append(errs, models.FieldError{Field: "end_date", Message: "должен быть MM-YYYY"})
+	}
+
+	if len(errs) == 0 && end.Before(start) {
+		errs = append(errs, models.FieldError{Field: "end_date", Message: "не может быть раньше start_date"})
+	}
+
+	if len(errs) > 0 {
+		return time.Time{}, time.Time{}, NewValidationError(errs)
+	}
+	return start, end, nil
+}
+
+// Разбор выражений фильтра по тегам из параметров tag
+func parseTagFilter(exprs []string) (models.TagFilter, error) {
+	filter, err := utils.ParseTagFilter(exprs)
+	if err != nil {
+		return nil, NewValidationError(err)
+	}
+	return filter, nil
+}
+
+// Необязательный фильтр user_id должен быть UUID
+func validateUserID(userID string) error {
+	if userID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return NewValidationError(models.FieldError{Field: "user_id", Message: "должен быть UUID"})
+	}
+	return nil
+}
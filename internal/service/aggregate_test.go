@@ -0,0 +1,74 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthlyBuckets(t *testing.T) {
+	start := month(time.January)
+	subs := []*models.Subscription{
+		{ServiceName: "Netflix", Price: 500, Currency: "RUB", StartDate: start, Status: models.StatusActive},
+		// Пауза в феврале
+		{
+			ServiceName: "Spotify", Price: 300, Currency: "RUB", StartDate: start, Status: models.StatusActive,
+			Pauses: []models.SubscriptionPause{{StartDate: month(time.February), EndDate: ptrTime(month(time.March))}},
+		},
+		// Пробный период в январе и феврале
+		{ServiceName: "Kion", Price: 200, Currency: "RUB", StartDate: start, Status: models.StatusActive, TrialMonths: 2, TrialUntil: ptrTime(month(time.March))},
+	}
+
+	buckets, err := monthlyBuckets(subs, start, month(time.March), converter{})
+	if err != nil {
+		t.Fatalf("monthlyBuckets error = %v", err)
+	}
+
+	tests := []struct {
+		month    string
+		active   int
+		total    int64
+		services []models.ServiceTotal
+	}{
+		{
+			month: "01-2025", active: 2, total: 800,
+			services: []models.ServiceTotal{{ServiceName: "Netflix", Currency: "RUB", Total: 500}, {ServiceName: "Spotify", Currency: "RUB", Total: 300}},
+		},
+		{
+			month: "02-2025", active: 1, total: 500,
+			services: []models.ServiceTotal{{ServiceName: "Netflix", Currency: "RUB", Total: 500}},
+		},
+		{
+			month: "03-2025", active: 3, total: 1000,
+			services: []models.ServiceTotal{
+				{ServiceName: "Netflix", Currency: "RUB", Total: 500}, {ServiceName: "Spotify", Currency: "RUB", Total: 300}, {ServiceName: "Kion", Currency: "RUB", Total: 200},
+			},
+		},
+	}
+
+	if len(buckets) != len(tests) {
+		t.Fatalf("len(buckets) = %d, want %d", len(buckets), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.month, func(t *testing.T) {
+			bucket := buckets[i]
+			if bucket.Month != tt.month {
+				t.Errorf("Month = %s, want %s", bucket.Month, tt.month)
+			}
+			if bucket.ActiveSubscriptions != tt.active {
+				t.Errorf("ActiveSubscriptions = %d, want %d", bucket.ActiveSubscriptions, tt.active)
+			}
+			if bucket.TotalPrice == nil || *bucket.TotalPrice != tt.total {
+				t.Errorf("TotalPrice = %v, want %d", bucket.TotalPrice, tt.total)
+			}
+			if !reflect.DeepEqual(bucket.Services, tt.services) {
+				t.Errorf("Services = %v, want %v", bucket.Services, tt.services)
+			}
+		})
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+func (s *service) CreateBudget(dto models.BudgetDTO) (*models.BudgetDTO, error) {
+	budget, err := models.ToBudget(dto)
+	if err != nil {
+		return nil, NewValidationError(err)
+	}
+	budget.ID = uuid.New().String()
+	if err := utils.ValidateBudget(budget); err != nil {
+		return nil, NewValidationError(err)
+	}
+
+	if err := s.repo.CreateBudget(budget); err != nil {
+		slog.Error("Не удалось создать бюджет", "error", err)
+		return nil, err
+	}
+
+	created := models.ToBudgetDTO(*budget)
+	return &created, nil
+}
+
+func (s *service) GetBudgets(userID string) ([]models.BudgetDTO, error) {
+	if err := validateUserID(userID); err != nil {
+		return nil, err
+	}
+
+	budgets, err := s.repo.GetBudgets(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]models.BudgetDTO, 0, len(budgets))
+	for _, budget := range budgets {
+		dtos = append(dtos, models.ToBudgetDTO(budget))
+	}
+	return dtos, nil
+}
+
+func (s *service) GetBudget(id string) (*models.BudgetDTO, error) {
+	budget, err := s.getBudget(id)
+	if err != nil {
+		return nil, err
+	}
+	dto := models.ToBudgetDTO(*budget)
+	return &dto, nil
+}
+
+func (s *service) DeleteBudget(id string) error {
+	return budgetError(s.repo.DeleteBudgetByID(id))
+}
+
+func (s *service) EvaluateBudget(id, monthStr string) (*models.BudgetEvaluation, error) {
+	now := utils.MonthStart(time.Now().UTC())
+	month := now
+	if monthStr != "" {
+		var err error
+		if month, err = time.Parse(monthLayout, monthStr); err != nil {
+			return nil, NewValidationError(models.FieldError{Field: "month", Message: "должен быть MM-YYYY"})
+		}
+	}
+
+	budget, err := s.getBudget(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluateBudget(budget, month, now)
+}
+
+func (s *service) GetBudgetBreaches(id string) ([]models.BudgetBreach, error) {
+	if _, err := s.getBudget(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetBudgetBreaches(id)
+}
+
+func (s *service) CheckBudgets() (int, error) {
+	budgets, err := s.repo.GetBudgets("")
+	if err != nil {
+		return 0, err
+	}
+
+	now := utils.MonthStart(time.Now().UTC())
+	exceeded := 0
+	for i := range budgets {
+		budget := &budgets[i]
+		// Бюджеты на период проверяются, пока период идёт
+		if budget.Period == models.BudgetPeriodRange && (now.Before(*budget.StartDate) || now.After(*budget.EndDate)) {
+			continue
+		}
+
+		evaluation, err := s.evaluateBudget(budget, now, now)
+		if err != nil {
+			slog.Error("Не удалось проверить бюджет", "budget_id", budget.ID, "error", err)
+			continue
+		}
+		if !evaluation.Exceeded {
+			continue
+		}
+		exceeded++
+
+		start, end := budgetPeriod(budget, now)
+		err = s.repo.SaveBudgetBreach(&models.BudgetBreach{
+			BudgetID:    budget.ID,
+			PeriodStart: start,
+			PeriodEnd:   end,
+			Limit:       evaluation.Limit,
+			Spent:       evaluation.Spent,
+			Currency:    evaluation.Currency,
+			DetectedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			slog.Error("Не удалось сохранить превышение бюджета", "budget_id", budget.ID, "error", err)
+			continue
+		}
+		slog.Warn("Бюджет превышен", "budget_id", budget.ID, "user_id", budget.UserID,
+			"limit", evaluation.Limit, "spent", evaluation.Spent, "currency", evaluation.Currency)
+	}
+	return exceeded, nil
+}
+
+// Фоновая проверка бюджетов: сразу после запуска и затем каждые interval,
+// пока не отменён ctx
+func RunBudgetChecker(ctx context.Context, svc Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		exceeded, err := svc.CheckBudgets()
+		if err != nil {
+			slog.Error("Не удалось выполнить проверку бюджетов", "error", err)
+		} else {
+			slog.Info("Проверка бюджетов завершена", "exceeded", exceeded)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *service) getBudget(id string) (*models.Budget, error) {
+	budget, err := s.repo.GetBudgetByID(id)
+	if err != nil {
+		return nil, budgetError(err)
+	}
+	return budget, nil
+}
+
+// Расходы по бюджету за период, содержащий month. Для бюджета на период
+// учитываются только месяцы не позже now — фактические, а не будущие расходы
+func (s *service) evaluateBudget(budget *models.Budget, month, now time.Time) (*models.BudgetEvaluation, error) {
+	start, end := budgetPeriod(budget, month)
+	spentUntil := end
+	if budget.Period == models.BudgetPeriodRange && spentUntil.After(now) {
+		spentUntil = now
+	}
+
+	evaluation := &models.BudgetEvaluation{
+		BudgetID: budget.ID,
+		From:     start.Format(monthLayout),
+		To:       end.Format(monthLayout),
+		Limit:    budget.Amount,
+		Currency: budget.Currency,
+	}
+
+	// Период ещё не начался — расходов нет
+	if !spentUntil.Before(start) {
+		query := models.AggregateQuery{
+			UserID:      budget.UserID,
+			ServiceName: budget.ServiceName,
+			StartDate:   start.Format(monthLayout),
+			EndDate:     spentUntil.Format(monthLayout),
+			ConvertTo:   budget.Currency,
+		}
+		// Без таблицы курсов сравнить можно только суммы в валюте бюджета
+		if s.rates == nil {
+			query.ConvertTo = ""
+		}
+
+		result, err := s.GetSubscriptionsPrice(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, total := range result.Totals {
+			if total.Currency != budget.Currency {
+				return nil, NewValidationError(models.FieldError{
+					Field:   "currency",
+					Message: fmt.Sprintf("расходы в %s нельзя сравнить с бюджетом в %s: таблица курсов валют не загружена", total.Currency, budget.Currency),
+				})
+			}
+			evaluation.Spent += total.Total
+		}
+	}
+
+	evaluation.Remaining = evaluation.Limit - evaluation.Spent
+	evaluation.Exceeded = evaluation.Spent > evaluation.Limit
+	return evaluation, nil
+}
+
+// Границы периода бюджета, содержащего month
+func budgetPeriod(budget *models.Budget, month time.Time) (time.Time, time.Time) {
+	if budget.Period == models.BudgetPeriodRange {
+		return *budget.StartDate, *budget.EndDate
+	}
+	month = utils.MonthStart(month)
+	return month, month
+}
+
+func budgetError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrBudgetNotFound
+	}
+	return err
+}
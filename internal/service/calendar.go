@@ -0,0 +1,24 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/utils"
+	"time"
+)
+
+// Календарь продлений действующих и будущих подписок пользователя (iCalendar)
+func (s *service) GetRenewalsCalendar(userID string) ([]byte, error) {
+	if userID == "" {
+		return nil, NewValidationError(models.FieldError{Field: "user_id", Message: "обязательное поле"})
+	}
+	if err := validateUserID(userID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	subs, err := s.repo.GetActiveSubscriptions(userID, now)
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return utils.RenewalCalendar(subs, now), nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+)
+
+func (s *service) GetCatalog() ([]models.CatalogServiceDTO, error) {
+	services, err := s.repo.GetCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]models.CatalogServiceDTO, 0, len(services))
+	for _, svc := range services {
+		dtos = append(dtos, models.ToCatalogServiceDTO(svc))
+	}
+	return dtos, nil
+}
+
+func (s *service) GetCatalogService(id string) (*models.CatalogServiceDTO, error) {
+	svc, err := s.repo.GetCatalogServiceByID(id)
+	if err != nil {
+		return nil, catalogError(err)
+	}
+	dto := models.ToCatalogServiceDTO(*svc)
+	return &dto, nil
+}
+
+// Новая запись справочника; подписки, записанные под её названием
+// или псевдонимами, переименовываются в каноническое название
+func (s *service) CreateCatalogService(dto models.CatalogServiceDTO, actor string) (*models.CatalogServiceDTO, error) {
+	dto.ID = uuid.New().String()
+	svc, err := s.validCatalogService(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		if err := repo.CreateCatalogService(svc); err != nil {
+			return err
+		}
+		_, err := renameSubscriptions(repo, svc, actor)
+		return err
+	})
+	if err != nil {
+		slog.Error("Не удалось добавить сервис в справочник", "error", err)
+		return nil, catalogError(err)
+	}
+
+	created := models.ToCatalogServiceDTO(*svc)
+	return &created, nil
+}
+
+// Обновление записи справочника. Прежнее название остаётся псевдонимом,
+// а сохранённые подписки переименовываются в новое каноническое название
+func (s *service) UpdateCatalogService(id string, dto models.CatalogServiceDTO, actor string) (*models.CatalogServiceDTO, error) {
+	dto.ID = id
+	svc, err := s.validCatalogService(dto)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.GetCatalogServiceByID(id)
+		if err != nil {
+			return err
+		}
+		if oldKey := utils.CatalogKey(current.Name); oldKey != utils.CatalogKey(svc.Name) && !hasAlias(svc, oldKey) {
+			svc.Aliases = append(svc.Aliases, models.CatalogAlias{ServiceID: svc.ID, Alias: oldKey})
+		}
+
+		if err := repo.UpdateCatalogService(svc); err != nil {
+			return err
+		}
+		_, err = renameSubscriptions(repo, svc, actor)
+		return err
+	})
+	if err != nil {
+		return nil, catalogError(err)
+	}
+
+	updated := models.ToCatalogServiceDTO(*svc)
+	return &updated, nil
+}
+
+func (s *service) DeleteCatalogService(id string) error {
+	return catalogError(s.repo.DeleteCatalogServiceByID(id))
+}
+
+func (s *service) NormalizeServiceNames(actor string) (*models.NormalizeResult, error) {
+	result := &models.NormalizeResult{}
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		services, err := repo.GetCatalog()
+		if err != nil {
+			return err
+		}
+		for i := range services {
+			renamed, err := renameSubscriptions(repo, &services[i], actor)
+			if err != nil {
+				return err
+			}
+			result.Renamed += renamed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return result, nil
+}
+
+// Переименовывает подписки, записанные под названием или псевдонимом сервиса svc,
+// в его каноническое название; каждое переименование попадает в историю изменений
+func renameSubscriptions(repo repository.Repository, svc *models.CatalogService, actor string) (int64, error) {
+	keys := []string{utils.CatalogKey(svc.Name)}
+	for _, alias := range svc.Aliases {
+		keys = append(keys, alias.Alias)
+	}
+
+	subs, err := repo.LockSubscriptionsByServiceNames(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	var renamed int64
+	for _, sub := range subs {
+		if sub.ServiceName == svc.Name {
+			continue
+		}
+		updated, err := repo.PatchSubscriptionByID(sub.ID, repository.AnyVersion, map[string]interface{}{"service_name": svc.Name})
+		if err != nil {
+			return 0, err
+		}
+		if err := recordChange(repo, models.ChangeUpdate, actor, sub, updated); err != nil {
+			return 0, err
+		}
+		renamed++
+	}
+	return renamed, nil
+}
+
+func hasAlias(svc *models.CatalogService, key string) bool {
+	for _, alias := range svc.Aliases {
+		if alias.Alias == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Запись справочника из DTO, проверенная на пересечение с остальными записями
+func (s *service) validCatalogService(dto models.CatalogServiceDTO) (*models.CatalogService, error) {
+	catalog, err := s.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	svc := models.ToCatalogService(dto)
+	if err := utils.ValidateCatalogService(svc, catalog); err != nil {
+		return nil, NewValidationError(err)
+	}
+	return svc, nil
+}
+
+// Справочник сервисов для нормализации названий и группировки по категориям
+func (s *service) loadCatalog() (utils.Catalog, error) {
+	services, err := s.repo.GetCatalog()
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewCatalog(services), nil
+}
+
+func catalogError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrCatalogNotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		return ErrCatalogConflict
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"errors"
+)
+
+func (s *service) GetSubscriptionDiscounts(id string) ([]models.SubscriptionDiscountDTO, error) {
+	if _, err := s.repo.GetSubscriptionByID(id); err != nil {
+		return nil, repoError(err)
+	}
+
+	discounts, err := s.repo.GetSubscriptionDiscounts(id)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := make([]models.SubscriptionDiscountDTO, 0, len(discounts))
+	for _, discount := range discounts {
+		dtos = append(dtos, models.ToSubscriptionDiscountDTO(discount))
+	}
+	return dtos, nil
+}
+
+func (s *service) AddSubscriptionDiscount(id string, dto models.SubscriptionDiscountDTO, actor string) (*models.SubscriptionDiscountDTO, error) {
+	discount, err := models.ToSubscriptionDiscount(dto)
+	if err != nil {
+		return nil, NewValidationError(err)
+	}
+	if err := utils.ValidateDiscount(discount); err != nil {
+		return nil, NewValidationError(err)
+	}
+
+	discount.SubscriptionID = id
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		if err := repo.CreateSubscriptionDiscount(discount); err != nil {
+			return err
+		}
+		return touchSubscription(repo, models.ChangeDiscountAdd, actor, current)
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	created := models.ToSubscriptionDiscountDTO(*discount)
+	return &created, nil
+}
+
+func (s *service) DeleteSubscriptionDiscount(id string, discountID uint64, actor string) error {
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		err = repo.DeleteSubscriptionDiscount(id, discountID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrDiscountNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return touchSubscription(repo, models.ChangeDiscountRemove, actor, current)
+	})
+	return repoError(err)
+}
+
+// Скидки хранятся отдельно от записи подписки, поэтому их изменение отмечается
+// явно: версия подписки (ETag) увеличивается, а в историю пишется action
+func touchSubscription(repo repository.Repository, action models.ChangeAction, actor string, current *models.Subscription) error {
+	updated, err := repo.PatchSubscriptionByID(current.ID, repository.AnyVersion, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	return recordChange(repo, action, actor, current, updated)
+}
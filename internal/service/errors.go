@@ -0,0 +1,64 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"errors"
+)
+
+var (
+	ErrNotFound = errors.New("подписка не найдена")
+	ErrConflict = errors.New("подписка конфликтует с существующей записью")
+	// Подписка изменена другим клиентом после получения её версии (ETag)
+	ErrPreconditionFailed = errors.New("подписка была изменена, получите актуальную версию")
+	// Переход между статусами подписки не допускается
+	ErrInvalidTransition = errors.New("недопустимый переход статуса подписки")
+
+	ErrBudgetNotFound   = errors.New("бюджет не найден")
+	ErrDiscountNotFound = errors.New("скидка не найдена")
+	ErrCatalogNotFound  = errors.New("сервис не найден в справочнике")
+	// Название или псевдоним уже принадлежит другому сервису справочника
+	ErrCatalogConflict = errors.New("название уже есть в справочнике")
+)
+
+// Ошибка валидации входных данных с подробностями по полям
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	return models.ValidationErrors(e.Fields).Error()
+}
+
+// Оборачивает ошибку разбора или проверки данных в ValidationError
+func NewValidationError(err error) error {
+	var validationErr *ValidationError
+	var fieldErrs models.ValidationErrors
+	var fieldErr models.FieldError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &validationErr):
+		return validationErr
+	case errors.As(err, &fieldErrs):
+		return &ValidationError{Fields: fieldErrs}
+	case errors.As(err, &fieldErr):
+		return &ValidationError{Fields: []models.FieldError{fieldErr}}
+	default:
+		return &ValidationError{Fields: []models.FieldError{{Message: err.Error()}}}
+	}
+}
+
+// Перевод ошибок репозитория в ошибки сервиса
+func repoError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return ErrNotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		return ErrConflict
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return ErrPreconditionFailed
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultForecastMonths = 12
+	maxForecastMonths     = 60
+)
+
+// Прогноз расходов пользователя на текущий и следующие месяцы.
+// Бессрочные подписки считаются продолжающимися, запланированные окончания
+// и будущие даты начала учитываются так же, как в помесячной агрегации
+func (s *service) GetForecast(query models.ForecastQuery) (*models.Forecast, error) {
+	if query.UserID == "" {
+		return nil, NewValidationError(models.FieldError{Field: "user_id", Message: "обязательное поле"})
+	}
+	if err := validateUserID(query.UserID); err != nil {
+		return nil, err
+	}
+
+	months := query.Months
+	if months == 0 {
+		months = defaultForecastMonths
+	}
+	if months < 0 || months > maxForecastMonths {
+		return nil, NewValidationError(models.FieldError{Field: "months", Message: fmt.Sprintf("должен быть от 1 до %d", maxForecastMonths)})
+	}
+
+	conv, err := s.newConverter(query.ConvertTo)
+	if err != nil {
+		return nil, err
+	}
+
+	start := utils.MonthStart(time.Now().UTC())
+	end := start.AddDate(0, months-1, 0)
+
+	subs, err := s.repo.GetCountSubscriptionsPrice(repository.PeriodFilter{
+		UserID:      query.UserID,
+		ServiceName: query.ServiceName,
+		Start:       start,
+		End:         end,
+		GroupBy:     []models.GroupDimension{models.GroupByServiceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := monthlyBuckets(subs, start, end, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	// Итоги по сервисам за весь горизонт
+	var totals currencyTotals
+	services := []models.ServiceTotal{}
+	serviceIndex := make(map[models.ServiceTotal]int)
+	for _, bucket := range buckets {
+		for _, total := range bucket.Services {
+			totals.add(total.Currency, total.Total)
+
+			key := models.ServiceTotal{ServiceName: total.ServiceName, Currency: total.Currency}
+			i, ok := serviceIndex[key]
+			if !ok {
+				i = len(services)
+				serviceIndex[key] = i
+				services = append(services, key)
+			}
+			services[i].Total += total.Total
+		}
+	}
+
+	return &models.Forecast{
+		UserID:     query.UserID,
+		From:       start.Format(monthLayout),
+		To:         end.Format(monthLayout),
+		TotalPrice: totals.single(),
+		Totals:     totals.list(),
+		Services:   services,
+		Months:     buckets,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"encoding/json"
+	"time"
+)
+
+// Актор изменений, если клиент его не указал
+const AnonymousActor = "anonymous"
+
+func (s *service) GetSubscriptionHistory(id string) ([]models.SubscriptionChange, error) {
+	changes, err := s.repo.GetSubscriptionHistory(id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return changes, nil
+}
+
+func (s *service) GetChanges(query models.ChangeQuery) (*models.ChangePage, error) {
+	changes, total, err := s.repo.GetChanges(query)
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return &models.ChangePage{
+		Data:   changes,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// Запись изменения в историю в той же транзакции, что и само изменение.
+// before или after равен nil для создания и удаления соответственно
+func recordChange(repo repository.Repository, action models.ChangeAction, actor string, before, after *models.Subscription) error {
+	if actor == "" {
+		actor = AnonymousActor
+	}
+
+	change := &models.SubscriptionChange{
+		Action:    action,
+		Actor:     actor,
+		ChangedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if before != nil {
+		change.SubscriptionID = before.ID
+		if change.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		change.SubscriptionID = after.ID
+		if change.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	return repo.CreateChange(change)
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"log/slog"
+)
+
+// Массовое создание подписок. Каждая строка проверяется так же, как в POST /subscription.
+// В режиме atomic при ошибке хотя бы в одной строке не сохраняется ничего,
+// в режиме best_effort сохраняются все корректные строки
+func (s *service) ImportSubscriptions(records []models.ImportRecord, mode models.ImportMode, actor string) (*models.ImportReport, error) {
+	report := &models.ImportReport{
+		Mode:  mode,
+		Total: len(records),
+		Rows:  make([]models.ImportRowResult, len(records)),
+	}
+
+	catalog, err := s.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	subs := make([]*models.Subscription, len(records))
+	for i, record := range records {
+		report.Rows[i].Line = record.Line
+		if record.Err != nil {
+			failRow(report, i, record.Err)
+			continue
+		}
+
+		sub, err := newSubscription(record.DTO, catalog)
+		if err != nil {
+			failRow(report, i, err)
+			continue
+		}
+		subs[i] = sub
+	}
+
+	if mode == models.ImportAtomic {
+		if report.Failed > 0 {
+			for i, sub := range subs {
+				if sub != nil {
+					report.Rows[i].Status = models.ImportSkipped
+				}
+			}
+			return report, nil
+		}
+
+		err := s.repo.Transaction(func(repo repository.Repository) error {
+			for _, sub := range subs {
+				if err := createSubscription(repo, sub, actor); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, repoError(err)
+		}
+		for i, sub := range subs {
+			createdRow(report, i, sub.ID)
+		}
+		return report, nil
+	}
+
+	// best_effort: каждая строка в своей транзакции, чтобы сбой одной не отменял остальные
+	for i, sub := range subs {
+		if sub == nil {
+			continue
+		}
+
+		err := s.repo.Transaction(func(repo repository.Repository) error {
+			return createSubscription(repo, sub, actor)
+		})
+		if err != nil {
+			slog.Error("Не удалось сохранить строку импорта", "line", report.Rows[i].Line, "error", err)
+			failRow(report, i, repoError(err))
+			continue
+		}
+		createdRow(report, i, sub.ID)
+	}
+	return report, nil
+}
+
+func createdRow(report *models.ImportReport, i int, id string) {
+	report.Rows[i].Status = models.ImportCreated
+	report.Rows[i].ID = id
+	report.Created++
+}
+
+func failRow(report *models.ImportReport, i int, err error) {
+	report.Rows[i].Status = models.ImportFailed
+	report.Rows[i].Errors = rowErrors(err)
+	report.Failed++
+}
+
+// Ошибки строки по полям; ошибка без поля — одной записью с текстом
+func rowErrors(err error) []models.FieldError {
+	return NewValidationError(err).(*ValidationError).Fields
+}
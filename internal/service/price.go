@@ -0,0 +1,80 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"time"
+)
+
+// История цен подписки; без изменений цены — одна запись с начала подписки
+func (s *service) GetSubscriptionPrices(id string) ([]models.PricePoint, error) {
+	sub, err := s.repo.GetSubscriptionByID(id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	prices, err := s.repo.GetSubscriptionPrices(id)
+	if err != nil {
+		return nil, repoError(err)
+	}
+	if len(prices) == 0 {
+		return []models.PricePoint{{EffectiveFrom: sub.StartDate.Format(monthLayout), Price: sub.Price}}, nil
+	}
+
+	points := make([]models.PricePoint, 0, len(prices))
+	for _, price := range prices {
+		points = append(points, models.PricePoint{EffectiveFrom: price.EffectiveFrom.Format(monthLayout), Price: price.Price})
+	}
+	return points, nil
+}
+
+// Запись новой цены в историю цен подписки. Прошлые месяцы сохраняют прежнюю цену,
+// поэтому при первом изменении в историю добавляется и цена с начала подписки.
+// Новая цена действует с effectiveFrom и дальше: более поздние записи истории удаляются
+func recordPriceChange(repo repository.Repository, before, after *models.Subscription, effectiveFrom time.Time) error {
+	startMonth := utils.MonthStart(after.StartDate)
+	if effectiveFrom.Before(startMonth) {
+		effectiveFrom = startMonth
+	}
+
+	prices, err := repo.GetSubscriptionPrices(after.ID)
+	if err != nil {
+		return err
+	}
+	if len(prices) == 0 {
+		// Новая цена действует с самого начала — история не нужна
+		if effectiveFrom.Equal(startMonth) {
+			return nil
+		}
+		if err := repo.SaveSubscriptionPrice(&models.SubscriptionPrice{
+			SubscriptionID: after.ID,
+			EffectiveFrom:  startMonth,
+			Price:          before.Price,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := repo.DeleteSubscriptionPricesAfter(after.ID, effectiveFrom); err != nil {
+		return err
+	}
+	return repo.SaveSubscriptionPrice(&models.SubscriptionPrice{
+		SubscriptionID: after.ID,
+		EffectiveFrom:  effectiveFrom,
+		Price:          after.Price,
+	})
+}
+
+// Месяц, с которого действует изменённая цена; по умолчанию — текущий
+func parsePriceEffectiveFrom(value *string) (time.Time, error) {
+	if value == nil || *value == "" {
+		return utils.MonthStart(time.Now().UTC()), nil
+	}
+
+	month, err := time.Parse(monthLayout, *value)
+	if err != nil {
+		return time.Time{}, NewValidationError(models.FieldError{Field: "price_effective_from", Message: "должен быть MM-YYYY"})
+	}
+	return month, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Репозиторий с историей цен в памяти
+type priceRepo struct {
+	repository.Repository
+	prices []models.SubscriptionPrice
+}
+
+func (r *priceRepo) GetSubscriptionPrices(id string) ([]models.SubscriptionPrice, error) {
+	return append([]models.SubscriptionPrice(nil), r.prices...), nil
+}
+
+func (r *priceRepo) SaveSubscriptionPrice(price *models.SubscriptionPrice) error {
+	for i := range r.prices {
+		if r.prices[i].EffectiveFrom.Equal(price.EffectiveFrom) {
+			r.prices[i].Price = price.Price
+			return nil
+		}
+	}
+	r.prices = append(r.prices, *price)
+	sort.Slice(r.prices, func(i, j int) bool { return r.prices[i].EffectiveFrom.Before(r.prices[j].EffectiveFrom) })
+	return nil
+}
+
+func (r *priceRepo) DeleteSubscriptionPricesAfter(id string, after time.Time) error {
+	kept := r.prices[:0]
+	for _, price := range r.prices {
+		if !price.EffectiveFrom.After(after) {
+			kept = append(kept, price)
+		}
+	}
+	r.prices = kept
+	return nil
+}
+
+func month(m time.Month) time.Time {
+	return time.Date(2025, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRecordPriceChange(t *testing.T) {
+	tests := []struct {
+		name          string
+		history       []models.SubscriptionPrice
+		before, after int
+		effectiveFrom time.Time
+		want          map[time.Month]int
+	}{
+		{
+			name:          "первое изменение сохраняет прежнюю цену в прошлых месяцах",
+			before:        100,
+			after:         150,
+			effectiveFrom: month(time.March),
+			want:          map[time.Month]int{time.January: 100, time.February: 100, time.March: 150, time.August: 150},
+		},
+		{
+			name: "более поздние записи истории заменяются новой ценой",
+			history: []models.SubscriptionPrice{
+				{EffectiveFrom: month(time.January), Price: 100},
+				{EffectiveFrom: month(time.March), Price: 150},
+				{EffectiveFrom: month(time.June), Price: 200},
+			},
+			before:        200,
+			after:         150,
+			effectiveFrom: month(time.March),
+			want:          map[time.Month]int{time.January: 100, time.March: 150, time.June: 150, time.August: 150},
+		},
+		{
+			name: "изменение с середины истории",
+			history: []models.SubscriptionPrice{
+				{EffectiveFrom: month(time.January), Price: 100},
+				{EffectiveFrom: month(time.June), Price: 200},
+			},
+			before:        200,
+			after:         120,
+			effectiveFrom: month(time.April),
+			want:          map[time.Month]int{time.March: 100, time.April: 120, time.June: 120, time.December: 120},
+		},
+		{
+			name:          "цена с начала подписки не требует истории",
+			before:        100,
+			after:         150,
+			effectiveFrom: month(time.January),
+			want:          map[time.Month]int{time.January: 150, time.August: 150},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &priceRepo{prices: tt.history}
+			before := &models.Subscription{ID: "sub", Price: tt.before, StartDate: month(time.January)}
+			after := *before
+			after.Price = tt.after
+
+			if err := recordPriceChange(repo, before, &after, tt.effectiveFrom); err != nil {
+				t.Fatalf("recordPriceChange: %v", err)
+			}
+
+			after.Prices = repo.prices
+			for m, want := range tt.want {
+				if got := utils.PriceAt(&after, month(m)); got != want {
+					t.Errorf("PriceAt(%s) = %d, want %d", m, got, want)
+				}
+			}
+		})
+	}
+}
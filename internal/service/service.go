@@ -4,30 +4,81 @@ import (
 	"aggregationSubscriptions/internal/models"
 	"aggregationSubscriptions/internal/repository"
 	"aggregationSubscriptions/internal/utils"
-	"errors"
+	"encoding/json"
 	"github.com/google/uuid"
 	"log/slog"
+	"strings"
 	"time"
 )
 
 type Service interface {
-	GetAllSubscriptions() ([]models.SubscriptionDTO, error)
+	GetAllSubscriptions(query models.ListQuery) (*models.SubscriptionPage, error)
+	// Выгрузка подписок по одной, без загрузки всей выборки в память
+	ExportSubscriptions(query models.ListQuery, fn func(dto models.SubscriptionDTO) error) error
 	GetSubscriptionByID(id string) (*models.SubscriptionDTO, error)
-	CreateNewSubscription(dto models.SubscriptionDTO) error
-	UpdateSubscription(id string, dto models.SubscriptionDTO) (*models.SubscriptionDTO, error)
-	DeleteSubscription(id string) error
-	GetSubscriptionsPrice(userID, serviceName, startStr, endStr string) (int64, error)
+	// Изменяющие методы записывают изменение в историю от имени actor.
+	// version — ожидаемая версия записи (из If-Match); repository.AnyVersion отключает проверку
+	CreateNewSubscription(dto models.SubscriptionDTO, actor string) (*models.SubscriptionDTO, error)
+	UpdateSubscription(id string, version int, dto models.SubscriptionDTO, actor string) (*models.SubscriptionDTO, error)
+	PatchSubscription(id string, version int, patch []byte, actor string) (*models.SubscriptionDTO, error)
+	DeleteSubscription(id string, version int, actor string) error
+	RestoreSubscription(id string, version int, actor string) (*models.SubscriptionDTO, error)
+	// Смена статуса: пауза и возобновление с месяца change.From, отмена с датой
+	// окончания change.EndDate (по умолчанию — текущий месяц)
+	PauseSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error)
+	ResumeSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error)
+	CancelSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error)
+	PurgeDeletedSubscriptions(retentionDays int, actor string) (*models.PurgeResult, error)
+	ImportSubscriptions(records []models.ImportRecord, mode models.ImportMode, actor string) (*models.ImportReport, error)
+	GetSubscriptionPrices(id string) ([]models.PricePoint, error)
+	GetSubscriptionDiscounts(id string) ([]models.SubscriptionDiscountDTO, error)
+	AddSubscriptionDiscount(id string, dto models.SubscriptionDiscountDTO, actor string) (*models.SubscriptionDiscountDTO, error)
+	DeleteSubscriptionDiscount(id string, discountID uint64, actor string) error
+	GetRenewalsCalendar(userID string) ([]byte, error)
+	GetTrialEndings(userID string, withinDays int) ([]models.TrialEnding, error)
+	GetSubscriptionHistory(id string) ([]models.SubscriptionChange, error)
+	GetChanges(query models.ChangeQuery) (*models.ChangePage, error)
+	GetSubscriptionsPrice(query models.AggregateQuery) (*models.AggregateResult, error)
+	GetSubscriptionsPriceByMonth(query models.AggregateQuery) ([]models.MonthlyBucket, error)
+	GetNormalizedMonthlyCosts(userID, serviceName, monthStr string) ([]models.NormalizedCost, error)
+	GetForecast(query models.ForecastQuery) (*models.Forecast, error)
+	CreateBudget(dto models.BudgetDTO) (*models.BudgetDTO, error)
+	GetBudgets(userID string) ([]models.BudgetDTO, error)
+	GetBudget(id string) (*models.BudgetDTO, error)
+	DeleteBudget(id string) error
+	// Расходы за месяц monthStr (MM-YYYY, по умолчанию текущий) для помесячного
+	// бюджета или за весь период — для бюджета на период
+	EvaluateBudget(id, monthStr string) (*models.BudgetEvaluation, error)
+	GetBudgetBreaches(id string) ([]models.BudgetBreach, error)
+	// Проверяет все бюджеты за текущий период и фиксирует превышения;
+	// возвращает число превышенных бюджетов
+	CheckBudgets() (int, error)
+	GetCatalog() ([]models.CatalogServiceDTO, error)
+	GetCatalogService(id string) (*models.CatalogServiceDTO, error)
+	CreateCatalogService(dto models.CatalogServiceDTO, actor string) (*models.CatalogServiceDTO, error)
+	UpdateCatalogService(id string, dto models.CatalogServiceDTO, actor string) (*models.CatalogServiceDTO, error)
+	DeleteCatalogService(id string) error
+	// Приводит названия всех подписок к каноническим названиям из справочника
+	NormalizeServiceNames(actor string) (*models.NormalizeResult, error)
 }
+
+const monthLayout = "01-2006"
+
+// Срок хранения мягко удалённых подписок по умолчанию
+const defaultRetentionDays = 30
+
 type service struct {
-	repo repository.Repository
+	repo  repository.Repository
+	rates *utils.CurrencyRates
 }
 
-func NewService(repo repository.Repository) Service {
-	return &service{repo: repo}
+// rates может быть nil — тогда пересчёт в другую валюту недоступен
+func NewService(repo repository.Repository, rates *utils.CurrencyRates) Service {
+	return &service{repo: repo, rates: rates}
 }
 
-func (s *service) GetAllSubscriptions() ([]models.SubscriptionDTO, error) {
-	sub, err := s.repo.GetAllSubscriptions()
+func (s *service) GetAllSubscriptions(query models.ListQuery) (*models.SubscriptionPage, error) {
+	sub, total, err := s.repo.GetAllSubscriptions(query)
 
 	if err != nil {
 		slog.Error("Не удалось найти записи подписок", "error", err)
@@ -35,98 +86,368 @@ func (s *service) GetAllSubscriptions() ([]models.SubscriptionDTO, error) {
 	}
 
 	// Конвертируем в DTO
-	var dtoList []models.SubscriptionDTO
+	dtoList := make([]models.SubscriptionDTO, 0, len(sub))
 	for _, s := range sub {
 		dtoList = append(dtoList, models.ToSubscriptionDTO(*s))
 	}
-	return dtoList, nil
+	return &models.SubscriptionPage{
+		Data:   dtoList,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+func (s *service) ExportSubscriptions(query models.ListQuery, fn func(dto models.SubscriptionDTO) error) error {
+	return s.repo.StreamSubscriptions(query, func(sub *models.Subscription) error {
+		return fn(models.ToSubscriptionDTO(*sub))
+	})
 }
 
 func (s *service) GetSubscriptionByID(id string) (*models.SubscriptionDTO, error) {
 	sub, err := s.repo.GetSubscriptionByID(id)
 	if err != nil {
 		slog.Error("Не удалось найти подписку", "error", err)
-		return nil, err
+		return nil, repoError(err)
 	}
 	dto := models.ToSubscriptionDTO(*sub)
 	return &dto, nil
 }
 
-func (s *service) CreateNewSubscription(dto models.SubscriptionDTO) error {
+func (s *service) CreateNewSubscription(dto models.SubscriptionDTO, actor string) (*models.SubscriptionDTO, error) {
+	catalog, err := s.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := newSubscription(dto, catalog)
+	if err != nil {
+		slog.Error("Не удалось создать запись", "error", err)
+		return nil, err
+	}
+
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		return createSubscription(repo, sub, actor)
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	created := models.ToSubscriptionDTO(*sub)
+	return &created, nil
+}
+
+// Новая подписка из DTO с присвоенным ID; ошибки — *ValidationError
+func newSubscription(dto models.SubscriptionDTO, catalog utils.Catalog) (*models.Subscription, error) {
 	sub, err := models.ToSubscription(dto)
 	if err != nil {
-		slog.Error("Ошибка с форматом данных даты", "error", err)
-		return err
+		return nil, NewValidationError(err)
 	}
 
 	sub.ID = uuid.New().String()
 
-	if err := utils.ValidateSubscription(sub); err != nil {
-		slog.Error("Не удалось создать запись", "error", err)
+	if err := utils.ValidateSubscription(sub, catalog); err != nil {
+		return nil, NewValidationError(err)
+	}
+	if sub.Status != models.StatusTrial && sub.Status != models.StatusActive {
+		return nil, NewValidationError(models.FieldError{Field: "status", Message: "при создании должен быть trial или active"})
+	}
+	// Подписка с пробным периодом начинается в статусе trial
+	if sub.TrialUntil = utils.TrialEnd(sub); sub.TrialUntil != nil {
+		sub.Status = models.StatusTrial
+	}
+	return sub, nil
+}
+
+// Сохранение новой подписки вместе с записью в истории изменений
+func createSubscription(repo repository.Repository, sub *models.Subscription, actor string) error {
+	if err := repo.CreateNewSubscription(sub); err != nil {
 		return err
 	}
-	return s.repo.CreateNewSubscription(sub)
+	return recordChange(repo, models.ChangeCreate, actor, nil, sub)
 }
 
-func (s *service) UpdateSubscription(id string, dto models.SubscriptionDTO) (*models.SubscriptionDTO, error) {
+func (s *service) UpdateSubscription(id string, version int, dto models.SubscriptionDTO, actor string) (*models.SubscriptionDTO, error) {
+	// Без status в теле запроса статус не меняется
+	keepStatus := strings.TrimSpace(string(dto.Status)) == ""
+
 	sub, err := models.ToSubscription(dto)
 	if err != nil {
-		return nil, err
+		return nil, NewValidationError(err)
 	}
 
-	if err := utils.ValidateSubscription(sub); err != nil {
+	catalog, err := s.loadCatalog()
+	if err != nil {
 		return nil, err
 	}
+	if err := utils.ValidateSubscription(sub, catalog); err != nil {
+		return nil, NewValidationError(err)
+	}
 
-	updatedSub, err := s.repo.UpdateSubscriptionByID(id, sub)
+	effectiveFrom, err := parsePriceEffectiveFrom(dto.PriceEffectiveFrom)
 	if err != nil {
 		return nil, err
 	}
 
+	var updatedSub *models.Subscription
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		if keepStatus {
+			sub.Status = current.Status
+		}
+		sub.TrialUntil = current.TrialUntil
+		applyTrial(current, sub)
+		if err := transition(repo, current, sub, time.Now().UTC()); err != nil {
+			return err
+		}
+		if updatedSub, err = repo.UpdateSubscriptionByID(id, version, sub); err != nil {
+			return err
+		}
+		if current.Price != updatedSub.Price {
+			if err := recordPriceChange(repo, current, updatedSub, effectiveFrom); err != nil {
+				return err
+			}
+		}
+		return recordChange(repo, models.ChangeUpdate, actor, current, updatedSub)
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
 	dtoResponse := models.ToSubscriptionDTO(*updatedSub)
 	return &dtoResponse, nil
 
 }
 
-func (s *service) DeleteSubscription(id string) error {
-	return s.repo.DeleteSubscriptionByID(id)
+// Частичное обновление по JSON Merge Patch (RFC 7396): патч накладывается
+// на текущее состояние, проверяется результат, сохраняются только изменённые колонки
+func (s *service) PatchSubscription(id string, version int, patch []byte, actor string) (*models.SubscriptionDTO, error) {
+	catalog, err := s.loadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedSub *models.Subscription
+	err = s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		if version != repository.AnyVersion && version != current.Version {
+			return ErrPreconditionFailed
+		}
+
+		merged, effectiveFrom, err := mergeSubscription(current, patch, catalog)
+		if err != nil {
+			return err
+		}
+		applyTrial(current, merged)
+		if err := transition(repo, current, merged, time.Now().UTC()); err != nil {
+			return err
+		}
+
+		changes := changedColumns(current, merged)
+		if len(changes) == 0 {
+			updatedSub = current
+			return nil
+		}
+
+		if updatedSub, err = repo.PatchSubscriptionByID(id, version, changes); err != nil {
+			return err
+		}
+		if current.Price != updatedSub.Price {
+			if err := recordPriceChange(repo, current, updatedSub, effectiveFrom); err != nil {
+				return err
+			}
+		}
+		return recordChange(repo, models.ChangeUpdate, actor, current, updatedSub)
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	dtoResponse := models.ToSubscriptionDTO(*updatedSub)
+	return &dtoResponse, nil
 }
 
-func (s *service) GetSubscriptionsPrice(userID, serviceName, startStr, endStr string) (int64, error) {
-	const monthLayout = "01-2006"
+// Накладывает патч на подписку и проверяет получившуюся запись.
+// Второе значение — месяц, с которого действует цена, если патч её меняет
+func mergeSubscription(current *models.Subscription, patch []byte, catalog utils.Catalog) (*models.Subscription, time.Time, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, time.Time{}, NewValidationError(models.FieldError{Message: "тело запроса должно быть JSON-объектом"})
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, time.Time{}, NewValidationError(models.FieldError{Message: "тело запроса должно быть JSON-объектом"})
+	}
 
-	// Парсинг дат
-	start, err := time.Parse(monthLayout, startStr)
+	currentJSON, err := json.Marshal(models.ToSubscriptionDTO(*current))
 	if err != nil {
-		return 0, err
+		return nil, time.Time{}, err
+	}
+	var currentDoc interface{}
+	if err := json.Unmarshal(currentJSON, &currentDoc); err != nil {
+		return nil, time.Time{}, err
 	}
 
-	end, err := time.Parse(monthLayout, endStr)
+	mergedJSON, err := json.Marshal(utils.MergePatch(currentDoc, patchDoc))
 	if err != nil {
-		return 0, err
+		return nil, time.Time{}, err
+	}
+	var dto models.SubscriptionDTO
+	if err := json.Unmarshal(mergedJSON, &dto); err != nil {
+		return nil, time.Time{}, NewValidationError(models.FieldError{Message: "неверный тип значения в патче"})
 	}
+	// Идентификатор патчем не меняется
+	dto.ID = current.ID
 
-	if end.Before(start) {
-		return 0, errors.New("end_date не может быть раньше start_date")
+	merged, err := models.ToSubscription(dto)
+	if err != nil {
+		return nil, time.Time{}, NewValidationError(err)
+	}
+	// Окончание пробного периода только для чтения
+	merged.TrialUntil = current.TrialUntil
+	if err := utils.ValidateSubscription(merged, catalog); err != nil {
+		return nil, time.Time{}, NewValidationError(err)
 	}
 
-	subs, err := s.repo.GetCountSubscriptionsPrice(userID, serviceName, start, end)
+	effectiveFrom, err := parsePriceEffectiveFrom(dto.PriceEffectiveFrom)
 	if err != nil {
-		return 0, err
+		return nil, time.Time{}, err
 	}
+	return merged, effectiveFrom, nil
+}
 
-	// Считаем итоговую цену
-	var total int64
-	for _, sub := range subs {
-		actualEnd := sub.EndDate
-		if actualEnd == nil || actualEnd.After(end) {
-			actualEnd = &end
+// Колонки, значения которых отличаются в after по сравнению с before
+func changedColumns(before, after *models.Subscription) map[string]interface{} {
+	changes := make(map[string]interface{})
+
+	if before.ServiceName != after.ServiceName {
+		changes["service_name"] = after.ServiceName
+	}
+	if before.Price != after.Price {
+		changes["price"] = after.Price
+	}
+	if before.Currency != after.Currency {
+		changes["currency"] = after.Currency
+	}
+	if before.BillingPeriod != after.BillingPeriod {
+		changes["billing_period"] = after.BillingPeriod
+	}
+	if before.UserID != after.UserID {
+		changes["user_id"] = after.UserID
+	}
+	if !before.StartDate.Equal(after.StartDate) {
+		changes["start_date"] = after.StartDate
+	}
+	switch {
+	case after.EndDate == nil && before.EndDate != nil:
+		changes["end_date"] = nil
+	case after.EndDate != nil && (before.EndDate == nil || !before.EndDate.Equal(*after.EndDate)):
+		changes["end_date"] = *after.EndDate
+	}
+	if before.DayPrecision != after.DayPrecision {
+		changes["day_precision"] = after.DayPrecision
+	}
+	if before.Status != after.Status {
+		changes["status"] = after.Status
+	}
+	switch {
+	case after.TrialUntil == nil && before.TrialUntil != nil:
+		changes["trial_until"] = nil
+	case after.TrialUntil != nil && (before.TrialUntil == nil || !before.TrialUntil.Equal(*after.TrialUntil)):
+		changes["trial_until"] = *after.TrialUntil
+	}
+	if before.TrialMonths != after.TrialMonths {
+		changes["trial_months"] = after.TrialMonths
+	}
+	switch {
+	case after.IntroPrice == nil && before.IntroPrice != nil:
+		changes["intro_price"] = nil
+	case after.IntroPrice != nil && (before.IntroPrice == nil || *before.IntroPrice != *after.IntroPrice):
+		changes["intro_price"] = *after.IntroPrice
+	}
+	if before.IntroMonths != after.IntroMonths {
+		changes["intro_months"] = after.IntroMonths
+	}
+	if !before.Tags.Equal(after.Tags) {
+		changes["tags"] = after.Tags
+	}
+	return changes
+}
+
+func (s *service) DeleteSubscription(id string, version int, actor string) error {
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		if err := repo.DeleteSubscriptionByID(id, version); err != nil {
+			return err
+		}
+		return recordChange(repo, models.ChangeDelete, actor, current, nil)
+	})
+	return repoError(err)
+}
+
+func (s *service) RestoreSubscription(id string, version int, actor string) (*models.SubscriptionDTO, error) {
+	var sub *models.Subscription
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, true)
+		if err != nil {
+			return err
+		}
+		if version != repository.AnyVersion && version != current.Version {
+			return ErrPreconditionFailed
+		}
+		// Действующая подписка возвращается без изменений и без записи в историю
+		if !current.DeletedAt.Valid {
+			sub = current
+			return nil
 		}
 
-		months := utils.MonthsBetween(sub.StartDate, *actualEnd)
-		total += int64(sub.Price * months)
+		if sub, err = repo.RestoreSubscriptionByID(id, version); err != nil {
+			return err
+		}
+		return recordChange(repo, models.ChangeRestore, actor, current, sub)
+	})
+	if err != nil {
+		return nil, repoError(err)
 	}
 
-	return total, nil
+	restored := models.ToSubscriptionDTO(*sub)
+	return &restored, nil
+}
+
+// Окончательное удаление подписок, удалённых более retentionDays дней назад;
+// 0 — срок по умолчанию
+func (s *service) PurgeDeletedSubscriptions(retentionDays int, actor string) (*models.PurgeResult, error) {
+	if retentionDays == 0 {
+		retentionDays = defaultRetentionDays
+	}
+	if retentionDays < 0 {
+		return nil, NewValidationError(models.FieldError{Field: "retention_days", Message: "должен быть > 0"})
+	}
 
+	before := time.Now().UTC().AddDate(0, 0, -retentionDays)
+	var purged []*models.Subscription
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		var err error
+		if purged, err = repo.PurgeDeletedSubscriptions(before); err != nil {
+			return err
+		}
+		for _, sub := range purged {
+			if err := recordChange(repo, models.ChangePurge, actor, sub, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+	return &models.PurgeResult{Purged: int64(len(purged)), DeletedBefore: before.Format(time.RFC3339)}, nil
 }
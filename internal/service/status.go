@@ -0,0 +1,157 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/repository"
+	"aggregationSubscriptions/internal/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *service) PauseSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error) {
+	return s.changeStatus(id, version, models.StatusPaused, models.ChangePause, change, actor)
+}
+
+func (s *service) ResumeSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error) {
+	return s.changeStatus(id, version, models.StatusActive, models.ChangeResume, change, actor)
+}
+
+func (s *service) CancelSubscription(id string, version int, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error) {
+	return s.changeStatus(id, version, models.StatusCancelled, models.ChangeCancel, change, actor)
+}
+
+// Перевод подписки версии version в статус to с записью в историю как action
+func (s *service) changeStatus(id string, version int, to models.SubscriptionStatus, action models.ChangeAction, change models.StatusChangeDTO, actor string) (*models.SubscriptionDTO, error) {
+	at := time.Now().UTC()
+	if change.From != nil {
+		month, err := time.Parse(monthLayout, *change.From)
+		if err != nil {
+			return nil, NewValidationError(models.FieldError{Field: "from", Message: "должен быть MM-YYYY"})
+		}
+		at = month
+	}
+
+	var updated *models.Subscription
+	err := s.repo.Transaction(func(repo repository.Repository) error {
+		current, err := repo.LockSubscriptionByID(id, false)
+		if err != nil {
+			return err
+		}
+		if version != repository.AnyVersion && version != current.Version {
+			return ErrPreconditionFailed
+		}
+		if current.Status == to {
+			return fmt.Errorf("%w: подписка уже в статусе %s", ErrInvalidTransition, to)
+		}
+		// Пробный период завершается изменением статуса, а не возобновлением
+		if action == models.ChangeResume && current.Status != models.StatusPaused {
+			return fmt.Errorf("%w: возобновить можно только приостановленную подписку", ErrInvalidTransition)
+		}
+
+		next := *current
+		next.Status = to
+		if to == models.StatusCancelled {
+			end := cancelEndDate(current, at)
+			if change.EndDate != nil {
+				if end, err = parseCancelEndDate(current, *change.EndDate); err != nil {
+					return err
+				}
+				next.EndDate = &end
+			} else if next.EndDate == nil || next.EndDate.After(end) {
+				// Запланированное окончание позже отмены переносится на дату отмены
+				next.EndDate = &end
+			}
+		}
+
+		if err := transition(repo, current, &next, at); err != nil {
+			return err
+		}
+		if updated, err = repo.PatchSubscriptionByID(id, version, changedColumns(current, &next)); err != nil {
+			return err
+		}
+		return recordChange(repo, action, actor, current, updated)
+	})
+	if err != nil {
+		return nil, repoError(err)
+	}
+
+	dto := models.ToSubscriptionDTO(*updated)
+	return &dto, nil
+}
+
+// Проверяет переход из статуса current в статус next, подготовленный к сохранению,
+// и вносит сопутствующие изменения с месяца at: пауза открывается или закрывается,
+// пробный период заканчивается, у отменённой подписки появляется дата окончания
+func transition(repo repository.Repository, current, next *models.Subscription, at time.Time) error {
+	from, to := current.Status, next.Status
+	if from == to {
+		if to == models.StatusCancelled && next.EndDate == nil {
+			return NewValidationError(models.FieldError{Field: "end_date", Message: "обязательное поле для отменённой подписки"})
+		}
+		return nil
+	}
+	if !utils.CanTransition(from, to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+
+	month := utils.MonthStart(at)
+	if to == models.StatusCancelled {
+		if next.EndDate == nil {
+			end := cancelEndDate(next, at)
+			next.EndDate = &end
+		}
+		if next.EndDate.Before(next.StartDate) {
+			return NewValidationError(models.FieldError{Field: "end_date", Message: "не может быть раньше start_date"})
+		}
+	}
+
+	// Пробный период заканчивается при выходе из trial; отменённый
+	// пробный период не оплачивается вовсе
+	if from == models.StatusTrial {
+		until := month
+		if to == models.StatusCancelled {
+			until = utils.MonthStart(*next.EndDate).AddDate(0, 1, 0)
+		}
+		if next.TrialUntil == nil || next.TrialUntil.After(until) {
+			next.TrialUntil = &until
+		}
+	}
+
+	switch {
+	case to == models.StatusPaused:
+		return repo.CreateSubscriptionPause(&models.SubscriptionPause{SubscriptionID: current.ID, StartDate: month})
+	case from == models.StatusPaused && to == models.StatusActive:
+		err := repo.EndSubscriptionPause(current.ID, month)
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewValidationError(models.FieldError{Field: "from", Message: "не может быть раньше начала паузы"})
+		}
+		return err
+	}
+	return nil
+}
+
+// Дата окончания по умолчанию при отмене в момент at: день отмены
+// для записей с точностью до дня, иначе месяц отмены
+func cancelEndDate(sub *models.Subscription, at time.Time) time.Time {
+	if sub.DayPrecision {
+		return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return utils.MonthStart(at)
+}
+
+// Дата окончания из запроса отмены, приведённая к точности дат подписки
+func parseCancelEndDate(sub *models.Subscription, value string) (time.Time, error) {
+	end, day, err := models.ParseDate(value)
+	if err != nil {
+		return time.Time{}, NewValidationError(models.FieldError{Field: "end_date", Message: "должен быть MM-YYYY или YYYY-MM-DD"})
+	}
+	switch {
+	case sub.DayPrecision && !day:
+		// Месяц окончания в записи с точностью до дня — последний день этого месяца
+		end = end.AddDate(0, 1, -1)
+	case !sub.DayPrecision && day:
+		end = utils.MonthStart(end)
+	}
+	return end, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"aggregationSubscriptions/internal/utils"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultTrialWindowDays = 30
+	maxTrialWindowDays     = 365
+)
+
+// Подписки, первое платное списание после пробного периода которых
+// приходится на ближайшие withinDays дней, начиная с сегодняшнего
+func (s *service) GetTrialEndings(userID string, withinDays int) ([]models.TrialEnding, error) {
+	if err := validateUserID(userID); err != nil {
+		return nil, err
+	}
+	if withinDays == 0 {
+		withinDays = defaultTrialWindowDays
+	}
+	if withinDays < 0 || withinDays > maxTrialWindowDays {
+		return nil, NewValidationError(models.FieldError{Field: "within_days", Message: fmt.Sprintf("должен быть от 1 до %d", maxTrialWindowDays)})
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, withinDays+1)
+
+	subs, err := s.repo.GetTrialSubscriptions(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	endings := []models.TrialEnding{}
+	for _, sub := range subs {
+		// Первое списание после пробного периода; уже прошедшее не интересно
+		first, ok := firstBillableDate(sub, *sub.TrialUntil, to)
+		if !ok || first.Before(from) {
+			continue
+		}
+
+		trialUntil := sub.TrialUntil.Format(monthLayout)
+		if sub.DayPrecision {
+			trialUntil = sub.TrialUntil.Format("2006-01-02")
+		}
+
+		endings = append(endings, models.TrialEnding{
+			ID:                sub.ID,
+			ServiceName:       sub.ServiceName,
+			UserID:            sub.UserID,
+			TrialUntil:        trialUntil,
+			FirstChargeDate:   first.Format("2006-01-02"),
+			FirstChargeAmount: int64(math.Round(utils.Discounted(sub, first, float64(utils.PriceAt(sub, first))))),
+			Currency:          sub.Currency,
+		})
+	}
+
+	sort.SliceStable(endings, func(i, j int) bool { return endings[i].FirstChargeDate < endings[j].FirstChargeDate })
+	return endings, nil
+}
+
+// Первая оплачиваемая дата списания в интервале [from, to)
+func firstBillableDate(sub *models.Subscription, from, to time.Time) (time.Time, bool) {
+	for _, date := range utils.BillingDates(sub, from, to) {
+		if utils.Billable(sub, date) {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Пересчёт окончания пробного периода, если изменились его длина
+// или дата начала подписки
+func applyTrial(current, next *models.Subscription) {
+	if next.TrialMonths == current.TrialMonths && next.StartDate.Equal(current.StartDate) {
+		return
+	}
+	if next.TrialMonths == 0 && current.TrialMonths == 0 {
+		return
+	}
+	next.TrialUntil = utils.TrialEnd(next)
+}
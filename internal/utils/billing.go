@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"math"
+	"time"
+)
+
+// Начисление по подписке: дата списания (для пропорциональной оплаты — первый
+// оплачиваемый день месяца), сумма к оплате и сумма скидки
+type Charge struct {
+	Date     time.Time
+	Amount   float64
+	Discount float64
+}
+
+// Дата n-го списания подписки, начиная с даты старта (n = 0)
+func AddBillingPeriods(start time.Time, period models.BillingPeriod, n int) time.Time {
+	switch period {
+	case models.BillingWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case models.BillingQuarterly:
+		return addMonths(start, 3*n)
+	case models.BillingYearly:
+		return addMonths(start, 12*n)
+	default:
+		return addMonths(start, n)
+	}
+}
+
+// Граница окончания действия подписки (не включая её): конец месяца end_date
+// для помесячных записей или день после end_date для записей с точностью до дня.
+// nil — подписка бессрочная
+func ActiveUntil(sub *models.Subscription) *time.Time {
+	if sub.EndDate == nil {
+		return nil
+	}
+
+	var until time.Time
+	if sub.DayPrecision {
+		until = sub.EndDate.AddDate(0, 0, 1)
+	} else {
+		until = MonthStart(*sub.EndDate).AddDate(0, 1, 0)
+	}
+	return &until
+}
+
+// Даты списаний подписки, попадающие в интервал [from, to)
+func BillingDates(sub *models.Subscription, from, to time.Time) []time.Time {
+	if until := ActiveUntil(sub); until != nil && until.Before(to) {
+		to = *until
+	}
+
+	var dates []time.Time
+	for n := firstBillingIndex(sub.StartDate, sub.BillingPeriod, from); ; n++ {
+		date := AddBillingPeriods(sub.StartDate, sub.BillingPeriod, n)
+		if !date.Before(to) {
+			break
+		}
+		if !date.Before(from) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// Начисления по подписке в интервале [from, to).
+// Ежемесячные подписки с точностью до дня оплачиваются пропорционально
+// числу дней действия в каждом календарном месяце, остальные — в даты списаний.
+// Списания в месяцы пробного периода и пауз не учитываются, к остальным
+// применяются действующие скидки
+func Charges(sub *models.Subscription, from, to time.Time) []Charge {
+	var charges []Charge
+	if sub.DayPrecision && (sub.BillingPeriod == models.BillingMonthly || sub.BillingPeriod == "") {
+		charges = proratedCharges(sub, from, to)
+	} else {
+		for _, date := range BillingDates(sub, from, to) {
+			charges = append(charges, Charge{Date: date, Amount: float64(PriceAt(sub, date))})
+		}
+	}
+
+	billable := charges[:0]
+	for _, charge := range charges {
+		if !Billable(sub, charge.Date) {
+			continue
+		}
+		net := Discounted(sub, charge.Date, charge.Amount)
+		charge.Discount = charge.Amount - net
+		charge.Amount = net
+		billable = append(billable, charge)
+	}
+	return billable
+}
+
+// Сумма списания amount в дату t после скидок, действующих в этом месяце:
+// сначала процентные, затем фиксированные; результат не бывает отрицательным.
+// Фиксированная скидка уменьшается пропорционально неполной оплате месяца
+func Discounted(sub *models.Subscription, t time.Time, amount float64) float64 {
+	if len(sub.Discounts) == 0 {
+		return amount
+	}
+
+	// Доля месяца, за которую выставлено списание
+	share := 1.0
+	if price := PriceAt(sub, t); price > 0 {
+		share = math.Min(amount/float64(price), 1)
+	}
+
+	month := MonthStart(t)
+	var fixed float64
+	for _, discount := range sub.Discounts {
+		if discount.ValidFrom != nil && month.Before(*discount.ValidFrom) {
+			continue
+		}
+		if discount.ValidUntil != nil && month.After(*discount.ValidUntil) {
+			continue
+		}
+
+		switch discount.Kind {
+		case models.DiscountPercent:
+			amount -= amount * float64(discount.Value) / 100
+		case models.DiscountFixed:
+			fixed += float64(discount.Value)
+		}
+	}
+
+	return math.Max(amount-fixed*share, 0)
+}
+
+// Окончание пробного периода длиной trial_months: первый оплачиваемый месяц,
+// а для записей с точностью до дня — первый оплачиваемый день.
+// nil — пробный период не задан
+func TrialEnd(sub *models.Subscription) *time.Time {
+	if sub.TrialMonths <= 0 {
+		return nil
+	}
+	until := MonthStart(sub.StartDate).AddDate(0, sub.TrialMonths, 0)
+	if sub.DayPrecision {
+		until = addMonths(sub.StartDate, sub.TrialMonths)
+	}
+	return &until
+}
+
+// Оплачивается ли списание в дату t: оно не входит в пробный период
+// и не приходится на месяц паузы
+func Billable(sub *models.Subscription, t time.Time) bool {
+	month := MonthStart(t)
+	if sub.Status == models.StatusTrial && sub.TrialUntil == nil {
+		return false
+	}
+	if sub.TrialUntil != nil && t.Before(*sub.TrialUntil) {
+		return false
+	}
+
+	for _, pause := range sub.Pauses {
+		if !month.Before(pause.StartDate) && (pause.EndDate == nil || month.Before(*pause.EndDate)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Итоговая сумма начислений с округлением до целого
+func SumCharges(charges []Charge) int64 {
+	var sum float64
+	for _, charge := range charges {
+		sum += charge.Amount
+	}
+	return int64(math.Round(sum))
+}
+
+// Сумма начислений без скидок с округлением до целого
+func SumGross(charges []Charge) int64 {
+	var sum float64
+	for _, charge := range charges {
+		sum += charge.Amount + charge.Discount
+	}
+	return int64(math.Round(sum))
+}
+
+func proratedCharges(sub *models.Subscription, from, to time.Time) []Charge {
+	if sub.StartDate.After(from) {
+		from = sub.StartDate
+	}
+	// Дни пробного периода не оплачиваются и не входят в долю месяца
+	if sub.TrialUntil != nil && sub.TrialUntil.After(from) {
+		from = *sub.TrialUntil
+	}
+	if until := ActiveUntil(sub); until != nil && until.Before(to) {
+		to = *until
+	}
+
+	var charges []Charge
+	for month := MonthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		next := month.AddDate(0, 1, 0)
+
+		lo, hi := month, next
+		if from.After(lo) {
+			lo = from
+		}
+		if to.Before(hi) {
+			hi = to
+		}
+		if !lo.Before(hi) {
+			continue
+		}
+
+		days := math.Round(hi.Sub(lo).Hours() / 24)
+		daysInMonth := math.Round(next.Sub(month).Hours() / 24)
+		charges = append(charges, Charge{Date: lo, Amount: float64(PriceAt(sub, month)) * days / daysInMonth})
+	}
+	return charges
+}
+
+// Цена подписки, действовавшая в месяце даты t. Вводная цена действует
+// intro_months месяцев после пробного периода, дальше — цена по истории цен:
+// без истории — текущая цена, для месяцев до первой записи — цена из неё
+func PriceAt(sub *models.Subscription, t time.Time) int {
+	month := MonthStart(t)
+	if sub.IntroPrice != nil {
+		introFrom := MonthStart(sub.StartDate)
+		if sub.TrialUntil != nil {
+			introFrom = MonthStart(*sub.TrialUntil)
+		}
+		if !month.Before(introFrom) && month.Before(introFrom.AddDate(0, sub.IntroMonths, 0)) {
+			return *sub.IntroPrice
+		}
+	}
+
+	if len(sub.Prices) == 0 {
+		return sub.Price
+	}
+
+	price := sub.Prices[0].Price
+	for _, entry := range sub.Prices {
+		if entry.EffectiveFrom.After(month) {
+			break
+		}
+		price = entry.Price
+	}
+	return price
+}
+
+// Сдвиг даты на n месяцев; число месяца ограничивается длиной целевого месяца,
+// чтобы 31 января + 1 месяц давало 28/29 февраля, а не начало марта
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// Номер списания, с которого имеет смысл начинать перебор дат до from
+func firstBillingIndex(start time.Time, period models.BillingPeriod, from time.Time) int {
+	if !from.After(start) {
+		return 0
+	}
+
+	var n int
+	switch period {
+	case models.BillingWeekly:
+		n = int(from.Sub(start).Hours() / 24 / 7)
+	case models.BillingQuarterly:
+		n = (MonthsBetween(start, from) - 1) / 3
+	case models.BillingYearly:
+		n = (MonthsBetween(start, from) - 1) / 12
+	default:
+		n = MonthsBetween(start, from) - 1
+	}
+
+	// Запас в одно списание на месяцы разной длины
+	if n > 0 {
+		n--
+	}
+	return n
+}
+
+// Стоимость подписки, приведённая к одному месяцу
+func MonthlyCost(price int, period models.BillingPeriod) float64 {
+	var cost float64
+	switch period {
+	case models.BillingWeekly:
+		cost = float64(price) * 52 / 12
+	case models.BillingQuarterly:
+		cost = float64(price) / 3
+	case models.BillingYearly:
+		cost = float64(price) / 12
+	default:
+		cost = float64(price)
+	}
+	return math.Round(cost*100) / 100
+}
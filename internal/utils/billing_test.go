@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestTrialEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  models.Subscription
+		want *time.Time
+	}{
+		{
+			name: "без пробного периода",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1)},
+		},
+		{
+			name: "помесячная запись",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1), TrialMonths: 2},
+			want: ptr(date(2025, time.March, 1)),
+		},
+		{
+			name: "с точностью до дня",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 15), TrialMonths: 1, DayPrecision: true},
+			want: ptr(date(2025, time.February, 15)),
+		},
+		{
+			name: "с точностью до дня, конец длинного месяца",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 31), TrialMonths: 1, DayPrecision: true},
+			want: ptr(date(2025, time.February, 28)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TrialEnd(&tt.sub)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("TrialEnd = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChargesAfterDayTrial(t *testing.T) {
+	// Пробный месяц с 15 января: в феврале оплачиваются дни с 15-го по 28-е
+	sub := &models.Subscription{
+		Price:         2800,
+		BillingPeriod: models.BillingMonthly,
+		StartDate:     date(2025, time.January, 15),
+		DayPrecision:  true,
+		TrialMonths:   1,
+	}
+	sub.TrialUntil = TrialEnd(sub)
+
+	tests := []struct {
+		name string
+		from time.Time
+		want int64
+	}{
+		{name: "месяц начала", from: date(2025, time.January, 1), want: 0},
+		{name: "месяц окончания пробного периода", from: date(2025, time.February, 1), want: 1400},
+		{name: "полный месяц", from: date(2025, time.March, 1), want: 2800},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SumCharges(Charges(sub, tt.from, tt.from.AddDate(0, 1, 0)))
+			if got != tt.want {
+				t.Errorf("SumCharges = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestBillingDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		sub      models.Subscription
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name: "ежемесячно",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1), BillingPeriod: models.BillingMonthly},
+			from: date(2025, time.March, 1), to: date(2025, time.June, 1),
+			want: []time.Time{date(2025, time.March, 1), date(2025, time.April, 1), date(2025, time.May, 1)},
+		},
+		{
+			name: "еженедельно",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 6), BillingPeriod: models.BillingWeekly},
+			from: date(2025, time.February, 1), to: date(2025, time.March, 1),
+			want: []time.Time{date(2025, time.February, 3), date(2025, time.February, 10), date(2025, time.February, 17), date(2025, time.February, 24)},
+		},
+		{
+			name: "ежеквартально",
+			sub:  models.Subscription{StartDate: date(2024, time.November, 1), BillingPeriod: models.BillingQuarterly},
+			from: date(2025, time.January, 1), to: date(2026, time.January, 1),
+			want: []time.Time{date(2025, time.February, 1), date(2025, time.May, 1), date(2025, time.August, 1), date(2025, time.November, 1)},
+		},
+		{
+			name: "ежегодно",
+			sub:  models.Subscription{StartDate: date(2023, time.March, 1), BillingPeriod: models.BillingYearly},
+			from: date(2025, time.January, 1), to: date(2026, time.January, 1),
+			want: []time.Time{date(2025, time.March, 1)},
+		},
+		{
+			name: "31 число в коротких месяцах",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 31), BillingPeriod: models.BillingMonthly, DayPrecision: true},
+			from: date(2025, time.February, 1), to: date(2025, time.May, 1),
+			want: []time.Time{date(2025, time.February, 28), date(2025, time.March, 31), date(2025, time.April, 30)},
+		},
+		{
+			name: "окончание помесячной записи включает месяц end_date",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1), EndDate: ptr(date(2025, time.February, 1)), BillingPeriod: models.BillingMonthly},
+			from: date(2025, time.January, 1), to: date(2025, time.June, 1),
+			want: []time.Time{date(2025, time.January, 1), date(2025, time.February, 1)},
+		},
+		{
+			name: "окончание записи с точностью до дня",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 10), EndDate: ptr(date(2025, time.March, 9)), BillingPeriod: models.BillingMonthly, DayPrecision: true},
+			from: date(2025, time.January, 1), to: date(2025, time.June, 1),
+			want: []time.Time{date(2025, time.January, 10), date(2025, time.February, 10)},
+		},
+		{
+			name: "интервал до начала подписки",
+			sub:  models.Subscription{StartDate: date(2025, time.June, 1), BillingPeriod: models.BillingMonthly},
+			from: date(2025, time.January, 1), to: date(2025, time.June, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BillingDates(&tt.sub, tt.from, tt.to)
+			if !equalDates(got, tt.want) {
+				t.Errorf("BillingDates = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalDates(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestChargesProrated(t *testing.T) {
+	tests := []struct {
+		name     string
+		sub      models.Subscription
+		from, to time.Time
+		want     int64
+	}{
+		{
+			name: "полный месяц",
+			sub:  models.Subscription{Price: 3000, StartDate: date(2025, time.January, 1), DayPrecision: true},
+			from: date(2025, time.April, 1), to: date(2025, time.May, 1),
+			want: 3000,
+		},
+		{
+			name: "старт в середине месяца",
+			sub:  models.Subscription{Price: 3000, StartDate: date(2025, time.April, 16), DayPrecision: true},
+			from: date(2025, time.April, 1), to: date(2025, time.May, 1),
+			want: 1500,
+		},
+		{
+			name: "окончание в середине месяца включает день end_date",
+			sub:  models.Subscription{Price: 3100, StartDate: date(2025, time.January, 1), EndDate: ptr(date(2025, time.March, 10)), DayPrecision: true},
+			from: date(2025, time.March, 1), to: date(2025, time.April, 1),
+			want: 1000,
+		},
+		{
+			name: "несколько месяцев",
+			sub:  models.Subscription{Price: 2800, StartDate: date(2025, time.February, 15), DayPrecision: true},
+			from: date(2025, time.January, 1), to: date(2025, time.April, 1),
+			want: 1400 + 2800,
+		},
+		{
+			name: "помесячная запись не пропорциональна",
+			sub:  models.Subscription{Price: 3000, StartDate: date(2025, time.April, 1)},
+			from: date(2025, time.April, 1), to: date(2025, time.May, 1),
+			want: 3000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SumCharges(Charges(&tt.sub, tt.from, tt.to)); got != tt.want {
+				t.Errorf("SumCharges = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceAt(t *testing.T) {
+	history := []models.SubscriptionPrice{
+		{EffectiveFrom: date(2025, time.January, 1), Price: 100},
+		{EffectiveFrom: date(2025, time.March, 1), Price: 150},
+	}
+
+	tests := []struct {
+		name string
+		sub  models.Subscription
+		at   time.Time
+		want int
+	}{
+		{name: "без истории — текущая цена", sub: models.Subscription{Price: 300}, at: date(2025, time.May, 1), want: 300},
+		{name: "до первой записи истории", sub: models.Subscription{Price: 150, Prices: history}, at: date(2024, time.December, 1), want: 100},
+		{name: "между записями", sub: models.Subscription{Price: 150, Prices: history}, at: date(2025, time.February, 20), want: 100},
+		{name: "с месяца новой цены", sub: models.Subscription{Price: 150, Prices: history}, at: date(2025, time.March, 15), want: 150},
+		{name: "после последней записи", sub: models.Subscription{Price: 150, Prices: history}, at: date(2025, time.August, 1), want: 150},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PriceAt(&tt.sub, tt.at); got != tt.want {
+				t.Errorf("PriceAt = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntroPriceAndTrial(t *testing.T) {
+	// Два пробных месяца, затем два месяца по вводной цене 50, дальше — 200
+	sub := &models.Subscription{
+		Price:       200,
+		StartDate:   date(2025, time.January, 1),
+		TrialMonths: 2,
+		IntroPrice:  ptr(50),
+		IntroMonths: 2,
+	}
+	sub.TrialUntil = TrialEnd(sub)
+
+	tests := []struct {
+		month    time.Time
+		billable bool
+		price    int
+	}{
+		{month: date(2025, time.January, 1), billable: false, price: 200},
+		{month: date(2025, time.February, 1), billable: false, price: 200},
+		{month: date(2025, time.March, 1), billable: true, price: 50},
+		{month: date(2025, time.April, 1), billable: true, price: 50},
+		{month: date(2025, time.May, 1), billable: true, price: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.month.Format("01-2006"), func(t *testing.T) {
+			if got := Billable(sub, tt.month); got != tt.billable {
+				t.Errorf("Billable = %v, want %v", got, tt.billable)
+			}
+			if got := PriceAt(sub, tt.month); got != tt.price {
+				t.Errorf("PriceAt = %d, want %d", got, tt.price)
+			}
+		})
+	}
+
+	// Бессрочный пробный период не оплачивается до смены статуса
+	trial := &models.Subscription{Price: 200, StartDate: date(2025, time.January, 1), Status: models.StatusTrial}
+	if Billable(trial, date(2025, time.June, 1)) {
+		t.Error("Billable = true для бессрочного пробного периода")
+	}
+}
+
+func TestDiscounted(t *testing.T) {
+	percent := func(value int) models.SubscriptionDiscount {
+		return models.SubscriptionDiscount{Kind: models.DiscountPercent, Value: value}
+	}
+	fixed := func(value int) models.SubscriptionDiscount {
+		return models.SubscriptionDiscount{Kind: models.DiscountFixed, Value: value}
+	}
+	march := date(2025, time.March, 1)
+
+	tests := []struct {
+		name      string
+		discounts []models.SubscriptionDiscount
+		amount    float64
+		want      float64
+	}{
+		{name: "без скидок", amount: 1000, want: 1000},
+		{name: "процентная", discounts: []models.SubscriptionDiscount{percent(20)}, amount: 1000, want: 800},
+		{name: "фиксированная", discounts: []models.SubscriptionDiscount{fixed(150)}, amount: 1000, want: 850},
+		{name: "сначала процентные, затем фиксированные", discounts: []models.SubscriptionDiscount{fixed(100), percent(10)}, amount: 1000, want: 800},
+		{name: "не меньше нуля", discounts: []models.SubscriptionDiscount{fixed(1500)}, amount: 1000, want: 0},
+		{name: "фиксированная пропорционально неполному месяцу", discounts: []models.SubscriptionDiscount{fixed(200)}, amount: 500, want: 400},
+		{
+			name:      "вне срока действия",
+			discounts: []models.SubscriptionDiscount{{Kind: models.DiscountPercent, Value: 50, ValidUntil: ptr(date(2025, time.February, 1))}},
+			amount:    1000, want: 1000,
+		},
+		{
+			name:      "в сроке действия",
+			discounts: []models.SubscriptionDiscount{{Kind: models.DiscountPercent, Value: 50, ValidFrom: ptr(march), ValidUntil: ptr(march)}},
+			amount:    1000, want: 500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &models.Subscription{Price: 1000, Discounts: tt.discounts}
+			if got := Discounted(sub, march, tt.amount); got != tt.want {
+				t.Errorf("Discounted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChargesGrossAndNet(t *testing.T) {
+	sub := &models.Subscription{
+		Price:     1000,
+		StartDate: date(2025, time.January, 1),
+		Discounts: []models.SubscriptionDiscount{{Kind: models.DiscountPercent, Value: 25, ValidUntil: ptr(date(2025, time.February, 1))}},
+	}
+
+	charges := Charges(sub, date(2025, time.January, 1), date(2025, time.April, 1))
+	if got := SumGross(charges); got != 3000 {
+		t.Errorf("SumGross = %d, want 3000", got)
+	}
+	if got := SumCharges(charges); got != 2500 {
+		t.Errorf("SumCharges = %d, want 2500", got)
+	}
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"fmt"
+	"strings"
+)
+
+// Справочник сервисов для поиска по названию: ключ — название или псевдоним
+// в нормализованном виде (см. CatalogKey). Пустой справочник ничего не находит
+type Catalog map[string]*models.CatalogService
+
+func NewCatalog(services []models.CatalogService) Catalog {
+	catalog := make(Catalog)
+	for i := range services {
+		svc := &services[i]
+		catalog[CatalogKey(svc.Name)] = svc
+		for _, alias := range svc.Aliases {
+			catalog[CatalogKey(alias.Alias)] = svc
+		}
+	}
+	return catalog
+}
+
+// Нормализованное написание названия: нижний регистр, без пробелов по краям
+// и с одиночными пробелами между словами
+func CatalogKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Сервис, названием или псевдонимом которого является name
+func (c Catalog) Lookup(name string) (*models.CatalogService, bool) {
+	svc, ok := c[CatalogKey(name)]
+	return svc, ok
+}
+
+// Категория сервиса; для сервисов вне справочника — models.UncategorizedCategory
+func (c Catalog) Category(name string) string {
+	if svc, ok := c.Lookup(name); ok {
+		return svc.Category
+	}
+	return models.UncategorizedCategory
+}
+
+// Проверка и нормализация записи справочника. Название и псевдонимы
+// не должны совпадать с названиями и псевдонимами других сервисов из catalog
+func ValidateCatalogService(svc *models.CatalogService, catalog Catalog) error {
+	var errs models.ValidationErrors
+
+	svc.Name = strings.Join(strings.Fields(svc.Name), " ")
+	if svc.Name == "" {
+		errs = append(errs, models.FieldError{Field: "name", Message: "обязательное поле"})
+	} else if owner, ok := catalog.Lookup(svc.Name); ok && owner.ID != svc.ID {
+		errs = append(errs, models.FieldError{Field: "name", Message: fmt.Sprintf("уже используется сервисом %s", owner.Name)})
+	}
+
+	svc.Category = CatalogKey(svc.Category)
+	if svc.Category == "" {
+		errs = append(errs, models.FieldError{Field: "category", Message: "обязательное поле"})
+	}
+
+	if svc.DefaultPrice != nil && *svc.DefaultPrice <= 0 {
+		errs = append(errs, models.FieldError{Field: "default_price", Message: "должен быть > 0"})
+	}
+
+	svc.Currency = strings.ToUpper(strings.TrimSpace(svc.Currency))
+	if svc.Currency == "" {
+		svc.Currency = models.DefaultCurrency
+	}
+	if !currencyCode.MatchString(svc.Currency) {
+		errs = append(errs, models.FieldError{Field: "currency", Message: "должен быть кодом валюты ISO 4217"})
+	}
+
+	// Псевдонимы хранятся нормализованными и без повторов
+	aliases := svc.Aliases[:0]
+	seen := map[string]bool{CatalogKey(svc.Name): true}
+	for _, alias := range svc.Aliases {
+		key := CatalogKey(alias.Alias)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if owner, ok := catalog[key]; ok && owner.ID != svc.ID {
+			errs = append(errs, models.FieldError{Field: "aliases", Message: fmt.Sprintf("%s уже используется сервисом %s", key, owner.Name)})
+		}
+		alias.Alias = key
+		alias.ServiceID = svc.ID
+		aliases = append(aliases, alias)
+	}
+	svc.Aliases = aliases
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Валюты нет в таблице курсов
+var ErrUnknownCurrency = errors.New("нет курса для валюты")
+
+// Таблица курсов: стоимость одной единицы валюты в базовой валюте
+type CurrencyRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Загрузка таблицы курсов из локального JSON-файла
+func LoadCurrencyRates(path string) (*CurrencyRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates CurrencyRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("неверный формат таблицы курсов: %w", err)
+	}
+
+	rates.Base = strings.ToUpper(rates.Base)
+	normalized := make(map[string]float64, len(rates.Rates)+1)
+	for code, rate := range rates.Rates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("курс %s должен быть > 0", code)
+		}
+		normalized[strings.ToUpper(code)] = rate
+	}
+	if rates.Base != "" {
+		normalized[rates.Base] = 1
+	}
+	rates.Rates = normalized
+	return &rates, nil
+}
+
+// Проверка, что валюта есть в таблице курсов
+func (r *CurrencyRates) Supports(currency string) bool {
+	_, ok := r.Rates[currency]
+	return ok
+}
+
+// Пересчёт суммы из одной валюты в другую с округлением до целого
+func (r *CurrencyRates) Convert(amount int64, from, to string) (int64, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := r.Rates[from]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, from)
+	}
+	toRate, ok := r.Rates[to]
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, to)
+	}
+
+	return int64(math.Round(float64(amount) * fromRate / toRate)), nil
+}
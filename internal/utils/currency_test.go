@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCurrencyRatesConvert(t *testing.T) {
+	rates := &CurrencyRates{Base: "RUB", Rates: map[string]float64{"RUB": 1, "USD": 90, "EUR": 100}}
+
+	tests := []struct {
+		name     string
+		amount   int64
+		from, to string
+		want     int64
+		wantErr  error
+	}{
+		{name: "та же валюта", amount: 500, from: "GBP", to: "GBP", want: 500},
+		{name: "в базовую валюту", amount: 10, from: "USD", to: "RUB", want: 900},
+		{name: "из базовой валюты с округлением", amount: 1000, from: "RUB", to: "USD", want: 11},
+		{name: "между небазовыми валютами", amount: 100, from: "EUR", to: "USD", want: 111},
+		{name: "нет курса исходной валюты", amount: 100, from: "GBP", to: "RUB", wantErr: ErrUnknownCurrency},
+		{name: "нет курса целевой валюты", amount: 100, from: "RUB", to: "GBP", wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rates.Convert(tt.amount, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
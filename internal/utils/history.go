@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Разбор параметров ленты изменений: from и to — RFC 3339 или YYYY-MM-DD
+func ParseChangeQuery(values url.Values) (models.ChangeQuery, error) {
+	query := models.ChangeQuery{Limit: defaultListLimit}
+
+	var err error
+	if query.From, err = parseTimestamp(values.Get("from"), "from"); err != nil {
+		return query, err
+	}
+	if query.To, err = parseTimestamp(values.Get("to"), "to"); err != nil {
+		return query, err
+	}
+	if query.From != nil && query.To != nil && query.To.Before(*query.From) {
+		return query, models.FieldError{Field: "to", Message: "не может быть раньше from"}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return query, models.FieldError{Field: "limit", Message: fmt.Sprintf("должен быть от 1 до %d", maxListLimit)}
+		}
+		query.Limit = limit
+	}
+
+	if v := values.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return query, models.FieldError{Field: "offset", Message: "должен быть >= 0"}
+		}
+		query.Offset = offset
+	}
+	return query, nil
+}
+
+func parseTimestamp(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, models.FieldError{Field: name, Message: "должен быть в формате RFC 3339 или YYYY-MM-DD"}
+	}
+	return &t, nil
+}
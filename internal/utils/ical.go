@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalDate     = "20060102"
+	icalDateTime = "20060102T150405Z"
+	// Максимальная длина строки календаря в октетах без CRLF (RFC 5545, 3.1)
+	icalLineLimit = 75
+)
+
+// Календарь продлений в формате iCalendar (RFC 5545): по одному повторяющемуся
+// событию на подписку, повторения заканчиваются с окончанием подписки
+func RenewalCalendar(subs []*models.Subscription, now time.Time) []byte {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		writeICalLine(&buf, fmt.Sprintf(format, args...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//aggregationSubscriptions//renewals//RU")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:%s", escapeICalText("Продления подписок"))
+
+	stamp := now.UTC().Format(icalDateTime)
+	for _, sub := range subs {
+		// У приостановленной подписки продлений нет до возобновления
+		if sub.Status == models.StatusPaused {
+			continue
+		}
+		first, ok := firstRenewal(sub)
+		if !ok {
+			continue
+		}
+		price := PriceAt(sub, now)
+		line("BEGIN:VEVENT")
+		line("UID:%s@aggregationSubscriptions", sub.ID)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART;VALUE=DATE:%s", first.Format(icalDate))
+		line("RRULE:%s", RecurrenceRule(sub))
+		line("SUMMARY:%s", escapeICalText(fmt.Sprintf("Продление %s: %d %s", sub.ServiceName, price, sub.Currency)))
+		line("DESCRIPTION:%s", escapeICalText(fmt.Sprintf("Списание %d %s, периодичность %s", price, sub.Currency, sub.BillingPeriod)))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// Первое платное списание — начало повторений в календаре: дата старта
+// или, при пробном периоде, первое списание после его окончания.
+// false — платных списаний нет: пробный период бессрочный
+// или подписка заканчивается раньше него
+func firstRenewal(sub *models.Subscription) (time.Time, bool) {
+	trialEnd := sub.TrialUntil
+	if trialEnd == nil {
+		trialEnd = TrialEnd(sub)
+	}
+	if trialEnd == nil {
+		return sub.StartDate, sub.Status != models.StatusTrial
+	}
+
+	dates := BillingDates(sub, *trialEnd, AddBillingPeriods(*trialEnd, sub.BillingPeriod, 1).AddDate(0, 0, 1))
+	if len(dates) == 0 {
+		return time.Time{}, false
+	}
+	return dates[0], true
+}
+
+// Правило повторения (RRULE) для дат списаний подписки.
+// Для 29–31 числа списание в коротких месяцах переносится на последний день,
+// как в AddBillingPeriods: BYMONTHDAY=d,-1 с BYSETPOS=1 выбирает меньшую из дат
+func RecurrenceRule(sub *models.Subscription) string {
+	var parts []string
+	switch sub.BillingPeriod {
+	case models.BillingWeekly:
+		parts = append(parts, "FREQ=WEEKLY")
+	case models.BillingQuarterly:
+		parts = append(parts, "FREQ=MONTHLY", "INTERVAL=3")
+	case models.BillingYearly:
+		parts = append(parts, "FREQ=YEARLY")
+	default:
+		parts = append(parts, "FREQ=MONTHLY")
+	}
+
+	if day := sub.StartDate.Day(); day > 28 && sub.BillingPeriod != models.BillingWeekly {
+		if sub.BillingPeriod == models.BillingYearly {
+			parts = append(parts, fmt.Sprintf("BYMONTH=%d", sub.StartDate.Month()))
+		}
+		parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d,-1", day), "BYSETPOS=1")
+	}
+
+	// UNTIL включает последнюю дату, поэтому берём день до конца действия
+	if until := ActiveUntil(sub); until != nil {
+		parts = append(parts, "UNTIL="+until.AddDate(0, 0, -1).Format(icalDate))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Экранирование значения типа TEXT (RFC 5545, 3.3.11)
+func escapeICalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// Запись строки с переносом длинных строк (RFC 5545, 3.1): продолжение
+// начинается с пробела, многобайтовые символы не разрываются
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Пробел в начале продолжения тоже занимает октет
+		limit = icalLineLimit - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
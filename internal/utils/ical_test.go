@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecurrenceRule(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  models.Subscription
+		want string
+	}{
+		{
+			name: "ежемесячно",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 15), BillingPeriod: models.BillingMonthly},
+			want: "FREQ=MONTHLY",
+		},
+		{
+			name: "еженедельно в конце месяца",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 31), BillingPeriod: models.BillingWeekly},
+			want: "FREQ=WEEKLY",
+		},
+		{
+			name: "ежеквартально 31 числа",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 31), BillingPeriod: models.BillingQuarterly},
+			want: "FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=31,-1;BYSETPOS=1",
+		},
+		{
+			name: "ежегодно 29 февраля",
+			sub:  models.Subscription{StartDate: date(2024, time.February, 29), BillingPeriod: models.BillingYearly},
+			want: "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29,-1;BYSETPOS=1",
+		},
+		{
+			name: "помесячная запись с окончанием",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1), EndDate: ptr(date(2025, time.June, 1)), BillingPeriod: models.BillingMonthly},
+			want: "FREQ=MONTHLY;UNTIL=20250630",
+		},
+		{
+			name: "запись с точностью до дня с окончанием",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 30), EndDate: ptr(date(2025, time.June, 10)), BillingPeriod: models.BillingMonthly, DayPrecision: true},
+			want: "FREQ=MONTHLY;BYMONTHDAY=30,-1;BYSETPOS=1;UNTIL=20250610",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecurrenceRule(&tt.sub); got != tt.want {
+				t.Errorf("RecurrenceRule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenewalCalendarTrial(t *testing.T) {
+	now := date(2025, time.January, 20)
+	tests := []struct {
+		name    string
+		sub     models.Subscription
+		dtstart string
+	}{
+		{
+			name:    "без пробного периода",
+			sub:     models.Subscription{StartDate: date(2025, time.January, 1), Status: models.StatusActive},
+			dtstart: "DTSTART;VALUE=DATE:20250101",
+		},
+		{
+			name:    "пробный период в месяцах",
+			sub:     models.Subscription{StartDate: date(2025, time.January, 1), Status: models.StatusTrial, TrialMonths: 2},
+			dtstart: "DTSTART;VALUE=DATE:20250301",
+		},
+		{
+			name: "пробный период с точностью до дня",
+			sub: models.Subscription{
+				StartDate: date(2025, time.January, 15), DayPrecision: true, Status: models.StatusTrial,
+				TrialMonths: 1, TrialUntil: ptr(date(2025, time.February, 15)),
+			},
+			dtstart: "DTSTART;VALUE=DATE:20250215",
+		},
+		{
+			name: "еженедельно после пробного месяца",
+			sub: models.Subscription{
+				StartDate: date(2025, time.January, 6), DayPrecision: true, BillingPeriod: models.BillingWeekly,
+				Status: models.StatusTrial, TrialMonths: 1, TrialUntil: ptr(date(2025, time.February, 6)),
+			},
+			dtstart: "DTSTART;VALUE=DATE:20250210",
+		},
+		{
+			name: "бессрочный пробный период",
+			sub:  models.Subscription{StartDate: date(2025, time.January, 1), Status: models.StatusTrial},
+		},
+		{
+			name: "подписка заканчивается в пробный период",
+			sub: models.Subscription{
+				StartDate: date(2025, time.January, 1), EndDate: ptr(date(2025, time.February, 1)),
+				Status: models.StatusTrial, TrialMonths: 3,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.sub.ID = "sub"
+			calendar := string(RenewalCalendar([]*models.Subscription{&tt.sub}, now))
+			if tt.dtstart == "" {
+				if strings.Contains(calendar, "BEGIN:VEVENT") {
+					t.Errorf("календарь содержит продление:\n%s", calendar)
+				}
+				return
+			}
+			if !strings.Contains(calendar, tt.dtstart+"\r\n") {
+				t.Errorf("календарь не содержит %s:\n%s", tt.dtstart, calendar)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Колонки CSV совпадают с полями JSON подписки
+var importColumns = map[string]bool{
+	"service_name":   true,
+	"price":          true,
+	"currency":       true,
+	"billing_period": true,
+	"user_id":        true,
+	"start_date":     true,
+	"end_date":       true,
+	"status":         true,
+	"trial_months":   true,
+	"intro_price":    true,
+	"intro_months":   true,
+	"tags":           true,
+}
+
+// Разбор режима импорта; по умолчанию atomic
+func ParseImportMode(value string) (models.ImportMode, error) {
+	switch mode := models.ImportMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return models.ImportAtomic, nil
+	case models.ImportAtomic, models.ImportBestEffort:
+		return mode, nil
+	default:
+		return "", models.FieldError{Field: "mode", Message: "должен быть atomic или best_effort"}
+	}
+}
+
+// Разбор формата импорта: явный format или тип содержимого
+func ParseImportFormat(format, contentType string) (models.ImportFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "csv":
+		return models.ImportCSV, nil
+	case "jsonl", "ndjson":
+		return models.ImportJSONL, nil
+	case "":
+	default:
+		return "", models.FieldError{Field: "format", Message: "должен быть csv или jsonl"}
+	}
+
+	switch contentType {
+	case "text/csv":
+		return models.ImportCSV, nil
+	case "application/jsonl", "application/x-ndjson", "application/x-jsonlines":
+		return models.ImportJSONL, nil
+	}
+	return "", models.FieldError{Field: "format", Message: "укажите format=csv или format=jsonl"}
+}
+
+// Чтение строк импорта. Ошибки отдельных строк попадают в ImportRecord.Err,
+// ошибка возвращается, только если файл нельзя разобрать целиком
+func DecodeImport(r io.Reader, format models.ImportFormat) ([]models.ImportRecord, error) {
+	if format == models.ImportCSV {
+		return decodeCSV(r)
+	}
+	return decodeJSONL(r)
+}
+
+// Первая строка CSV — заголовок с названиями колонок
+func decodeCSV(r io.Reader) ([]models.ImportRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать заголовок CSV: %w", err)
+	}
+	for i, column := range header {
+		// Excel добавляет BOM в начало файла
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !importColumns[column] {
+			return nil, models.FieldError{Field: column, Message: "неизвестная колонка CSV"}
+		}
+		header[i] = column
+	}
+
+	var records []models.ImportRecord
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var record models.ImportRecord
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			record.Line = parseErr.StartLine
+			record.Err = models.FieldError{Message: parseErr.Err.Error()}
+		case err != nil:
+			return nil, fmt.Errorf("не удалось прочитать CSV: %w", err)
+		case len(row) != len(header):
+			record.Line, _ = reader.FieldPos(0)
+			record.Err = models.FieldError{Message: fmt.Sprintf("ожидается %d колонок, получено %d", len(header), len(row))}
+		default:
+			record.Line, _ = reader.FieldPos(0)
+			record.DTO, record.Err = csvRowToDTO(header, row)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func csvRowToDTO(header, row []string) (models.SubscriptionDTO, error) {
+	var dto models.SubscriptionDTO
+	for i, column := range header {
+		value := strings.TrimSpace(row[i])
+		switch column {
+		case "service_name":
+			dto.ServiceName = value
+		case "price":
+			price, err := strconv.Atoi(value)
+			if err != nil {
+				return dto, models.FieldError{Field: "price", Message: "должен быть целым числом"}
+			}
+			dto.Price = price
+		case "currency":
+			dto.Currency = value
+		case "billing_period":
+			dto.BillingPeriod = models.BillingPeriod(value)
+		case "user_id":
+			dto.UserID = value
+		case "start_date":
+			dto.StartDate = value
+		case "end_date":
+			if value != "" {
+				dto.EndDate = &value
+			}
+		case "status":
+			dto.Status = models.SubscriptionStatus(value)
+		case "tags":
+			// Теги через пробел: "team:backend cost-center:42"
+			dto.Tags = strings.Fields(value)
+		case "trial_months", "intro_months", "intro_price":
+			// Необязательные числовые колонки
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return dto, models.FieldError{Field: column, Message: "должен быть целым числом"}
+			}
+			switch column {
+			case "trial_months":
+				dto.TrialMonths = n
+			case "intro_months":
+				dto.IntroMonths = n
+			default:
+				dto.IntroPrice = &n
+			}
+		}
+	}
+	return dto, nil
+}
+
+// Одна подписка в формате JSON на строку; пустые строки пропускаются
+func decodeJSONL(r io.Reader) ([]models.ImportRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var records []models.ImportRecord
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		record := models.ImportRecord{Line: line}
+		if err := json.Unmarshal(data, &record.DTO); err != nil {
+			record.Err = models.FieldError{Message: "неверный JSON: " + err.Error()}
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать JSONL: %w", err)
+	}
+	return records, nil
+}
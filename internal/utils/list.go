@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// Колонки, по которым разрешена сортировка списка подписок
+var sortableColumns = map[string]bool{
+	"id":             true,
+	"service_name":   true,
+	"price":          true,
+	"currency":       true,
+	"billing_period": true,
+	"user_id":        true,
+	"start_date":     true,
+	"end_date":       true,
+}
+
+// Разбор параметров запроса списка подписок
+func ParseListQuery(values url.Values) (models.ListQuery, error) {
+	query := models.ListQuery{
+		UserID:      strings.TrimSpace(values.Get("user_id")),
+		ServiceName: strings.TrimSpace(values.Get("service_name")),
+		Limit:       defaultListLimit,
+	}
+
+	if query.UserID != "" {
+		if _, err := uuid.Parse(query.UserID); err != nil {
+			return query, models.FieldError{Field: "user_id", Message: "должен быть UUID"}
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return query, models.FieldError{Field: "limit", Message: fmt.Sprintf("должен быть от 1 до %d", maxListLimit)}
+		}
+		query.Limit = limit
+	}
+
+	if v := values.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return query, models.FieldError{Field: "offset", Message: "должен быть >= 0"}
+		}
+		query.Offset = offset
+	}
+
+	// Месяц (MM-YYYY) — подписки, действующие хотя бы день в этом месяце,
+	// дата (YYYY-MM-DD) — действующие в этот день
+	if v := values.Get("active_at"); v != "" {
+		from, day, err := models.ParseDate(v)
+		if err != nil {
+			return query, models.FieldError{Field: "active_at", Message: "должен быть MM-YYYY или YYYY-MM-DD"}
+		}
+		to := from.AddDate(0, 1, 0)
+		if day {
+			to = from.AddDate(0, 0, 1)
+		}
+		query.ActiveFrom, query.ActiveTo = &from, &to
+	}
+
+	var err error
+	if query.MinPrice, err = parseOptionalInt(values.Get("min_price"), "min_price"); err != nil {
+		return query, err
+	}
+	if query.MaxPrice, err = parseOptionalInt(values.Get("max_price"), "max_price"); err != nil {
+		return query, err
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MaxPrice < *query.MinPrice {
+		return query, models.FieldError{Field: "max_price", Message: "не может быть меньше min_price"}
+	}
+
+	if query.Sort, err = ParseSort(values.Get("sort")); err != nil {
+		return query, err
+	}
+
+	if query.Tags, err = ParseTagFilter(values["tag"]); err != nil {
+		return query, err
+	}
+
+	if v := values.Get("include_deleted"); v != "" {
+		if query.IncludeDeleted, err = strconv.ParseBool(v); err != nil {
+			return query, models.FieldError{Field: "include_deleted", Message: "должен быть true или false"}
+		}
+	}
+	return query, nil
+}
+
+// Разбор параметра sort вида "service_name,-price": минус — по убыванию
+func ParseSort(sort string) ([]models.SortField, error) {
+	var fields []models.SortField
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := models.SortField{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !sortableColumns[field.Column] {
+			return nil, models.FieldError{Field: "sort", Message: fmt.Sprintf("сортировка по %s не поддерживается", field.Column)}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func parseOptionalInt(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, models.FieldError{Field: name, Message: "должен быть целым числом"}
+	}
+	return &n, nil
+}
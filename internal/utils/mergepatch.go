@@ -0,0 +1,27 @@
+package utils
+
+// Применение JSON Merge Patch (RFC 7396) к документу, разобранному
+// через encoding/json в interface{}. Исходный документ не изменяется
+func MergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	result := make(map[string]interface{}, len(targetObj)+len(patchObj))
+	if ok {
+		for key, value := range targetObj {
+			result[key] = value
+		}
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		result[key] = MergePatch(result[key], value)
+	}
+	return result
+}
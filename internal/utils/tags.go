@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Ключ тега: латиница в нижнем регистре, цифры и _ . - /
+	tagKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_./-]{0,63}$`)
+	// Значение тега не содержит пробелов и разделителей выражений фильтра
+	tagValuePattern = regexp.MustCompile(`^[^\s,|]{0,128}$`)
+)
+
+// Проверка ключей и значений тегов подписки
+func validateTags(tags models.Tags) models.ValidationErrors {
+	var errs models.ValidationErrors
+	for _, label := range tags.List() {
+		key, value := models.ParseTag(label)
+		if !tagKeyPattern.MatchString(key) || !tagValuePattern.MatchString(value) {
+			errs = append(errs, models.FieldError{Field: "tags", Message: fmt.Sprintf("неверный тег %q: ожидается key или key:value без пробелов", label)})
+		}
+	}
+	return errs
+}
+
+// Разбор выражений фильтра по тегам, например "team:backend|team:frontend,!env:test":
+// запятая — И, вертикальная черта — ИЛИ, восклицательный знак — отрицание,
+// тег без значения — наличие ключа. Несколько выражений объединяются по И
+func ParseTagFilter(exprs []string) (models.TagFilter, error) {
+	var filter models.TagFilter
+	for _, expr := range exprs {
+		for _, part := range strings.Split(expr, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+
+			var group []models.TagCondition
+			for _, term := range strings.Split(part, "|") {
+				term = strings.TrimSpace(term)
+				negate := strings.HasPrefix(term, "!")
+				key, value := models.ParseTag(strings.TrimPrefix(term, "!"))
+				if !tagKeyPattern.MatchString(key) || !tagValuePattern.MatchString(value) {
+					return nil, models.FieldError{Field: "tag", Message: fmt.Sprintf("неверное условие %q: ожидается key, key:value или !key:value", term)}
+				}
+				group = append(group, models.TagCondition{Key: key, Value: value, Negate: negate})
+			}
+			filter = append(filter, group)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"aggregationSubscriptions/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		exprs   []string
+		want    models.TagFilter
+		wantErr bool
+	}{
+		{name: "пустой фильтр", exprs: nil},
+		{
+			name:  "наличие ключа",
+			exprs: []string{"team"},
+			want:  models.TagFilter{{{Key: "team"}}},
+		},
+		{
+			name:  "ключ и значение",
+			exprs: []string{"Team:backend"},
+			want:  models.TagFilter{{{Key: "team", Value: "backend"}}},
+		},
+		{
+			name:  "ИЛИ, И и отрицание",
+			exprs: []string{"team:backend|team:frontend, !env:test"},
+			want: models.TagFilter{
+				{{Key: "team", Value: "backend"}, {Key: "team", Value: "frontend"}},
+				{{Key: "env", Value: "test", Negate: true}},
+			},
+		},
+		{
+			name:  "несколько выражений объединяются по И",
+			exprs: []string{"team:backend", "env"},
+			want:  models.TagFilter{{{Key: "team", Value: "backend"}}, {{Key: "env"}}},
+		},
+		{
+			name:  "пустые части пропускаются",
+			exprs: []string{",team,"},
+			want:  models.TagFilter{{{Key: "team"}}},
+		},
+		{name: "пустое условие в ИЛИ", exprs: []string{"team|"}, wantErr: true},
+		{name: "неверный ключ", exprs: []string{"командa:x"}, wantErr: true},
+		{name: "пробел в значении", exprs: []string{"team:back end"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTagFilter(tt.exprs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTagFilter error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTagFilter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy string
+		want    []models.GroupDimension
+		wantErr bool
+	}{
+		{name: "без измерений", groupBy: " , ", wantErr: true},
+		{
+			name:    "несколько измерений без повторов",
+			groupBy: "service_name, user_id,service_name",
+			want:    []models.GroupDimension{models.GroupByServiceName, models.GroupByUserID},
+		},
+		{
+			name:    "по ключу тега",
+			groupBy: "category,tag:Team",
+			want:    []models.GroupDimension{models.GroupByCategory, models.GroupByTag("team")},
+		},
+		{name: "два ключа тега", groupBy: "tag:team,tag:env", wantErr: true},
+		{name: "неверный ключ тега", groupBy: "tag:", wantErr: true},
+		{name: "неизвестное измерение", groupBy: "price", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGroupBy(tt.groupBy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGroupBy error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGroupBy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
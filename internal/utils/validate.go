@@ -4,50 +4,261 @@ import (
 	"aggregationSubscriptions/internal/models"
 	"fmt"
 	"github.com/google/uuid"
+	"regexp"
 	"strings"
 	"time"
 )
 
-const monthLayout = "01-2006"
+// Код валюты ISO 4217
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Проверка и нормализация подписки; возвращает models.ValidationErrors
+// со всеми найденными ошибками по полям. Название сервиса из справочника
+// catalog заменяется каноническим, а пустая цена — ценой по умолчанию
+func ValidateSubscription(sub *models.Subscription, catalog Catalog) error {
+	var errs models.ValidationErrors
 
-func ValidateSubscription(sub *models.Subscription) error {
 	sub.ServiceName = strings.TrimSpace(sub.ServiceName)
 	sub.UserID = strings.TrimSpace(sub.UserID)
 
+	if svc, ok := catalog.Lookup(sub.ServiceName); ok {
+		sub.ServiceName = svc.Name
+		if sub.Price == 0 && svc.DefaultPrice != nil {
+			sub.Price = *svc.DefaultPrice
+			if strings.TrimSpace(sub.Currency) == "" {
+				sub.Currency = svc.Currency
+			}
+		}
+	}
+
 	if sub.ServiceName == "" {
-		return fmt.Errorf("service_name обязателен")
+		errs = append(errs, models.FieldError{Field: "service_name", Message: "обязательное поле"})
 	}
 
 	if sub.Price <= 0 {
-		return fmt.Errorf("price должен быть > 0")
+		errs = append(errs, models.FieldError{Field: "price", Message: "должен быть > 0"})
+	}
+
+	sub.Currency = strings.ToUpper(strings.TrimSpace(sub.Currency))
+	if sub.Currency == "" {
+		sub.Currency = models.DefaultCurrency
+	}
+	if !currencyCode.MatchString(sub.Currency) {
+		errs = append(errs, models.FieldError{Field: "currency", Message: "должен быть кодом валюты ISO 4217"})
+	}
+
+	sub.BillingPeriod = models.BillingPeriod(strings.ToLower(strings.TrimSpace(string(sub.BillingPeriod))))
+	switch sub.BillingPeriod {
+	case "":
+		sub.BillingPeriod = models.BillingMonthly
+	case models.BillingWeekly, models.BillingMonthly, models.BillingQuarterly, models.BillingYearly:
+	default:
+		errs = append(errs, models.FieldError{Field: "billing_period", Message: "должен быть weekly, monthly, quarterly или yearly"})
 	}
 
 	if _, err := uuid.Parse(sub.UserID); err != nil {
-		return fmt.Errorf("неверный user_id")
+		errs = append(errs, models.FieldError{Field: "user_id", Message: "должен быть UUID"})
 	}
 
-	startStr := sub.StartDate.Format(monthLayout)
-	_, err := time.Parse(monthLayout, startStr)
-	if err != nil {
-		return fmt.Errorf("start_date должен быть в формате MM-YYYY")
+	sub.Status = models.SubscriptionStatus(strings.ToLower(strings.TrimSpace(string(sub.Status))))
+	switch sub.Status {
+	case "":
+		sub.Status = models.StatusActive
+	case models.StatusTrial, models.StatusActive, models.StatusPaused, models.StatusCancelled:
+	default:
+		errs = append(errs, models.FieldError{Field: "status", Message: "должен быть trial, active, paused или cancelled"})
 	}
 
-	if sub.EndDate != nil {
-		endStr := sub.EndDate.Format(monthLayout)
-		end, err := time.Parse(monthLayout, endStr)
-		if err != nil {
-			return fmt.Errorf("end_date должен быть в формате MM-YYYY")
-		}
-		if end.Before(sub.StartDate) {
-			return fmt.Errorf("end_date не может быть раньше start_date")
-		}
+	if sub.StartDate.IsZero() {
+		errs = append(errs, models.FieldError{Field: "start_date", Message: "обязательное поле"})
+	}
+
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		errs = append(errs, models.FieldError{Field: "end_date", Message: "не может быть раньше start_date"})
 	}
 
+	if sub.TrialMonths < 0 {
+		errs = append(errs, models.FieldError{Field: "trial_months", Message: "должен быть >= 0"})
+	}
+	switch {
+	case sub.IntroPrice == nil && sub.IntroMonths != 0:
+		errs = append(errs, models.FieldError{Field: "intro_price", Message: "обязательное поле при заданном intro_months"})
+	case sub.IntroPrice != nil && sub.IntroMonths <= 0:
+		errs = append(errs, models.FieldError{Field: "intro_months", Message: "должен быть > 0 при заданной intro_price"})
+	case sub.IntroPrice != nil && *sub.IntroPrice < 0:
+		errs = append(errs, models.FieldError{Field: "intro_price", Message: "должен быть >= 0"})
+	}
+
+	errs = append(errs, validateTags(sub.Tags)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
+// Допустимые переходы между статусами подписки; cancelled — конечный статус
+var statusTransitions = map[models.SubscriptionStatus][]models.SubscriptionStatus{
+	models.StatusTrial:  {models.StatusActive, models.StatusPaused, models.StatusCancelled},
+	models.StatusActive: {models.StatusPaused, models.StatusCancelled},
+	models.StatusPaused: {models.StatusActive, models.StatusCancelled},
+}
+
+// Можно ли перевести подписку из статуса from в статус to
+func CanTransition(from, to models.SubscriptionStatus) bool {
+	for _, allowed := range statusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
 func MonthsBetween(start, end time.Time) int {
 	years := end.Year() - start.Year()
 	months := int(end.Month()) - int(start.Month())
 	return years*12 + months + 1
 }
+
+// Разбор параметра group_by вида "service_name,user_id", "category" или "tag:team".
+// Группировать можно только по одному ключу тега
+func ParseGroupBy(groupBy string) ([]models.GroupDimension, error) {
+	var dims []models.GroupDimension
+	seen := make(map[models.GroupDimension]bool)
+
+	for _, part := range strings.Split(groupBy, ",") {
+		dim := models.GroupDimension(strings.TrimSpace(part))
+		if dim == "" {
+			continue
+		}
+		if key, ok := dim.TagKey(); ok {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if !tagKeyPattern.MatchString(key) {
+				return nil, models.FieldError{Field: "group_by", Message: fmt.Sprintf("неверный ключ тега: %s", key)}
+			}
+			if prev, ok := models.TagGroupKey(dims); ok && prev != key {
+				return nil, models.FieldError{Field: "group_by", Message: "группировать можно только по одному ключу тега"}
+			}
+			dim = models.GroupByTag(key)
+		} else {
+			switch dim {
+			case models.GroupByServiceName, models.GroupByUserID, models.GroupByCategory:
+			default:
+				return nil, models.FieldError{Field: "group_by", Message: fmt.Sprintf("неизвестное измерение группировки: %s", dim)}
+			}
+		}
+		if !seen[dim] {
+			seen[dim] = true
+			dims = append(dims, dim)
+		}
+	}
+
+	if len(dims) == 0 {
+		return nil, models.FieldError{Field: "group_by", Message: "обязательный параметр"}
+	}
+	return dims, nil
+}
+
+// Число месяцев подписки, попадающих в период [start, end].
+// Месяц, в котором подписка действовала хотя бы день, учитывается целиком
+func OverlapMonths(subStart time.Time, subEnd *time.Time, start, end time.Time) int {
+	from := MonthStart(subStart)
+	if from.Before(start) {
+		from = start
+	}
+
+	to := end
+	if subEnd != nil && subEnd.Before(end) {
+		to = MonthStart(*subEnd)
+	}
+
+	if to.Before(from) {
+		return 0
+	}
+	return MonthsBetween(from, to)
+}
+
+// Первое число месяца, в который попадает дата
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Проверка и нормализация бюджета; возвращает models.ValidationErrors
+func ValidateBudget(budget *models.Budget) error {
+	var errs models.ValidationErrors
+
+	budget.UserID = strings.TrimSpace(budget.UserID)
+	budget.ServiceName = strings.TrimSpace(budget.ServiceName)
+
+	if _, err := uuid.Parse(budget.UserID); err != nil {
+		errs = append(errs, models.FieldError{Field: "user_id", Message: "должен быть UUID"})
+	}
+
+	if budget.Amount <= 0 {
+		errs = append(errs, models.FieldError{Field: "limit", Message: "должен быть > 0"})
+	}
+
+	budget.Currency = strings.ToUpper(strings.TrimSpace(budget.Currency))
+	if budget.Currency == "" {
+		budget.Currency = models.DefaultCurrency
+	}
+	if !currencyCode.MatchString(budget.Currency) {
+		errs = append(errs, models.FieldError{Field: "currency", Message: "должен быть кодом валюты ISO 4217"})
+	}
+
+	budget.Period = models.BudgetPeriod(strings.ToLower(strings.TrimSpace(string(budget.Period))))
+	switch budget.Period {
+	case "", models.BudgetMonthly:
+		budget.Period = models.BudgetMonthly
+		if budget.StartDate != nil || budget.EndDate != nil {
+			errs = append(errs, models.FieldError{Field: "period", Message: "start_date и end_date задаются только для period"})
+		}
+	case models.BudgetPeriodRange:
+		if budget.StartDate == nil {
+			errs = append(errs, models.FieldError{Field: "start_date", Message: "обязательное поле для period"})
+		}
+		if budget.EndDate == nil {
+			errs = append(errs, models.FieldError{Field: "end_date", Message: "обязательное поле для period"})
+		}
+		if budget.StartDate != nil && budget.EndDate != nil && budget.EndDate.Before(*budget.StartDate) {
+			errs = append(errs, models.FieldError{Field: "end_date", Message: "не может быть раньше start_date"})
+		}
+	default:
+		errs = append(errs, models.FieldError{Field: "period", Message: "должен быть monthly или period"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Проверка и нормализация скидки; возвращает models.ValidationErrors
+func ValidateDiscount(discount *models.SubscriptionDiscount) error {
+	var errs models.ValidationErrors
+
+	discount.Code = strings.TrimSpace(discount.Code)
+
+	discount.Kind = models.DiscountKind(strings.ToLower(strings.TrimSpace(string(discount.Kind))))
+	switch discount.Kind {
+	case models.DiscountPercent:
+		if discount.Value <= 0 || discount.Value > 100 {
+			errs = append(errs, models.FieldError{Field: "value", Message: "должен быть от 1 до 100"})
+		}
+	case models.DiscountFixed:
+		if discount.Value <= 0 {
+			errs = append(errs, models.FieldError{Field: "value", Message: "должен быть > 0"})
+		}
+	default:
+		errs = append(errs, models.FieldError{Field: "kind", Message: "должен быть percent или fixed"})
+	}
+
+	if discount.ValidFrom != nil && discount.ValidUntil != nil && discount.ValidUntil.Before(*discount.ValidFrom) {
+		errs = append(errs, models.FieldError{Field: "valid_until", Message: "не может быть раньше valid_from"})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
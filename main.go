@@ -6,13 +6,19 @@ import (
 	"aggregationSubscriptions/internal/handler"
 	"aggregationSubscriptions/internal/repository"
 	"aggregationSubscriptions/internal/service"
+	"aggregationSubscriptions/internal/utils"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
 	"log/slog"
 	"os"
+	"time"
 )
 
+// Интервал фоновой проверки бюджетов по умолчанию
+const defaultBudgetCheckInterval = time.Hour
+
 // @title Aggregation Subscriptions API
 // @version 1.0
 // @description API для управления подписками пользователей.
@@ -21,14 +27,28 @@ import (
 func main() {
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, nil)))
 
-	database.Connect()
-	database.Migrate()
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
 
-	db := database.GetDB()
-	subRepository := repository.NewRepository(db)
-	subService := service.NewService(subRepository)
+	subService := newService()
 	subHandler := handler.NewHandler(subService)
 
+	// Фоновая проверка бюджетов; BUDGET_CHECK_INTERVAL — интервал в формате
+	// time.ParseDuration, "0" отключает проверку
+	checkInterval := defaultBudgetCheckInterval
+	if v := os.Getenv("BUDGET_CHECK_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			slog.Error("Неверный BUDGET_CHECK_INTERVAL", slog.String("value", v))
+			os.Exit(1)
+		}
+		checkInterval = d
+	}
+	if checkInterval > 0 {
+		go service.RunBudgetChecker(context.Background(), subService, checkInterval)
+	}
+
 	router := gin.Default()
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -36,9 +56,64 @@ func main() {
 	router.GET("/subscription/:id", subHandler.GetSubscription)
 	router.POST("/subscription", subHandler.CreateSubscription)
 	router.PUT("/subscription/:id", subHandler.UpdateSubscription)
+	router.PATCH("/subscription/:id", subHandler.PatchSubscription)
 	router.DELETE("/subscription/:id", subHandler.DeleteSubscription)
+	router.POST("/subscription/:id/restore", subHandler.RestoreSubscription)
+	router.POST("/subscription/:id/pause", subHandler.PauseSubscription)
+	router.POST("/subscription/:id/resume", subHandler.ResumeSubscription)
+	router.POST("/subscription/:id/cancel", subHandler.CancelSubscription)
+	router.GET("/subscription/:id/prices", subHandler.GetSubscriptionPrices)
+	router.GET("/subscription/:id/discounts", subHandler.GetSubscriptionDiscounts)
+	router.POST("/subscription/:id/discounts", subHandler.AddSubscriptionDiscount)
+	router.DELETE("/subscription/:id/discounts/:discount_id", subHandler.DeleteSubscriptionDiscount)
+	router.GET("/subscription/:id/history", subHandler.GetSubscriptionHistory)
+	router.GET("/subscriptions/changes", subHandler.GetChanges)
+	router.POST("/subscriptions/import", subHandler.ImportSubscriptions)
+	router.GET("/subscriptions/trials", subHandler.GetTrialEndings)
+	router.GET("/users/:user_id/renewals.ics", subHandler.GetRenewalsCalendar)
+	router.GET("/users/:user_id/forecast", subHandler.GetForecast)
 	router.GET("/subscriptions/aggregate/total", subHandler.GetSubscriptionsPrice)
+	router.GET("/subscriptions/aggregate/breakdown", subHandler.GetSubscriptionsPriceBreakdown)
+	router.GET("/subscriptions/aggregate/monthly", subHandler.GetSubscriptionsPriceByMonth)
+	router.GET("/subscriptions/aggregate/normalized", subHandler.GetNormalizedMonthlyCosts)
+
+	router.GET("/budgets", subHandler.GetBudgets)
+	router.POST("/budgets", subHandler.CreateBudget)
+	router.GET("/budgets/:id", subHandler.GetBudget)
+	router.DELETE("/budgets/:id", subHandler.DeleteBudget)
+	router.GET("/budgets/:id/evaluation", subHandler.EvaluateBudget)
+	router.GET("/budgets/:id/breaches", subHandler.GetBudgetBreaches)
+
+	router.GET("/catalog", subHandler.GetCatalog)
+	router.POST("/catalog", subHandler.CreateCatalogService)
+	router.GET("/catalog/:id", subHandler.GetCatalogService)
+	router.PUT("/catalog/:id", subHandler.UpdateCatalogService)
+	router.DELETE("/catalog/:id", subHandler.DeleteCatalogService)
+
+	admin := router.Group("/admin")
+	admin.POST("/subscriptions/purge", subHandler.PurgeDeletedSubscriptions)
+	admin.POST("/subscriptions/normalize", subHandler.NormalizeServiceNames)
 
 	slog.Info("Сервер запущен на http://localhost:8080")
 	router.Run(":8080")
 }
+
+// Подключение к БД и сборка сервиса подписок
+func newService() service.Service {
+	database.Connect()
+	database.Migrate()
+
+	// Таблица курсов для пересчёта итогов в одну валюту
+	ratesPath := os.Getenv("CURRENCY_RATES_FILE")
+	if ratesPath == "" {
+		ratesPath = "rates.json"
+	}
+	rates, err := utils.LoadCurrencyRates(ratesPath)
+	if err != nil {
+		slog.Warn("Не удалось загрузить таблицу курсов, пересчёт валют недоступен", slog.String("error", err.Error()))
+	}
+
+	db := database.GetDB()
+	subRepository := repository.NewRepository(db)
+	return service.NewService(subRepository, rates)
+}